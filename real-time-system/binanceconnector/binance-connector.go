@@ -0,0 +1,323 @@
+package binanceconnector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+	"github.com/369geofreeman/inventory-control/real-time-system/reconnect"
+	"github.com/369geofreeman/inventory-control/real-time-system/wsconn"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	binanceWSURL   = "wss://fstream.binance.com/stream"
+	binanceRESTURL = "https://fapi.binance.com"
+
+	defaultSnapshotLimit = 1000 // Number of levels requested in the REST snapshot
+
+	defaultStaleTimeout = 60 * time.Second // Reconnect if no message arrives within this time
+	pongTimeout         = 10 * time.Second
+)
+
+var _ marketdata.Source = (*Client)(nil)
+
+// Config defines what a Client subscribes to
+type Config struct {
+	Symbols       []string // Symbols to subscribe to, e.g. "BTCUSDT", "ETHUSDT"
+	SnapshotLimit int      // Levels requested in REST snapshots, defaults to 1000
+	URL           string   // Combined stream WebSocket URL, defaults to Binance USD-M futures
+	RESTURL       string   // REST base URL, defaults to Binance USD-M futures
+
+	StaleTimeout time.Duration // Reconnect when no message arrives within this time, defaults to 60s
+
+	// Binance closes every connection after 24h, so by default the client
+	// never gives up reconnecting
+	Reconnect reconnect.Config
+}
+
+// Client maintains a single combined stream connection to Binance USD-M
+// futures and publishes the normalized messages for every subscribed symbol
+// to its Feed. Order books are bootstrapped from REST snapshots.
+// Client implements marketdata.Source.
+type Client struct {
+	url           string
+	restURL       string
+	snapshotLimit int
+	httpClient    *http.Client
+
+	subscriptions map[string]*subscription
+	streams       map[string]*subscription // Subscriptions by stream name
+	order         []string                 // Symbols in the order they were configured
+
+	staleTimeout time.Duration
+	reconnect    reconnect.Config
+}
+
+// snapshotResult is the outcome of an asynchronous REST snapshot request
+type snapshotResult struct {
+	sub      *subscription
+	snapshot DepthSnapshot
+	err      error
+}
+
+// NewClient validates the config and creates a Feed for each symbol
+func NewClient(config Config) (*Client, error) {
+	if len(config.Symbols) == 0 {
+		return nil, errors.New("at least one symbol is required")
+	}
+
+	c := &Client{
+		url:           config.URL,
+		restURL:       config.RESTURL,
+		snapshotLimit: config.SnapshotLimit,
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+		subscriptions: make(map[string]*subscription, len(config.Symbols)),
+		streams:       make(map[string]*subscription, 3*len(config.Symbols)),
+		staleTimeout:  config.StaleTimeout,
+		reconnect:     config.Reconnect.WithDefaults(),
+	}
+	if c.url == "" {
+		c.url = binanceWSURL
+	}
+	if c.restURL == "" {
+		c.restURL = binanceRESTURL
+	}
+	if c.snapshotLimit == 0 {
+		c.snapshotLimit = defaultSnapshotLimit
+	}
+	if c.staleTimeout <= 0 {
+		c.staleTimeout = defaultStaleTimeout
+	}
+
+	for _, symbol := range config.Symbols {
+		symbol = strings.ToUpper(symbol)
+		if _, ok := c.subscriptions[symbol]; ok {
+			return nil, fmt.Errorf("duplicate symbol %s", symbol)
+		}
+		sub := newSubscription(symbol)
+		c.subscriptions[symbol] = sub
+		for _, stream := range sub.streams() {
+			c.streams[stream] = sub
+		}
+		c.order = append(c.order, symbol)
+	}
+	return c, nil
+}
+
+// Venue returns the name of the venue
+func (c *Client) Venue() string {
+	return venue
+}
+
+// Feed returns the feed for the given symbol
+func (c *Client) Feed(symbol string) (*marketdata.Feed, bool) {
+	sub, ok := c.subscriptions[strings.ToUpper(symbol)]
+	if !ok {
+		return nil, false
+	}
+	return sub.feed, true
+}
+
+// Feeds returns every feed in the order the symbols were configured
+func (c *Client) Feeds() []*marketdata.Feed {
+	feeds := make([]*marketdata.Feed, 0, len(c.order))
+	for _, symbol := range c.order {
+		feeds = append(feeds, c.subscriptions[symbol].feed)
+	}
+	return feeds
+}
+
+// Connect connects to Binance and processes messages for every feed,
+// reconnecting with a jittered exponential backoff when the connection fails.
+// It returns ctx's error once ctx is cancelled, or reconnect.ErrGaveUp once
+// Reconnect.MaxAttempts consecutive connections have failed. Whenever a connection ends every feed is reset, as
+// events are lost while disconnected and the state from before the gap can no
+// longer be trusted.
+func (c *Client) Connect(ctx context.Context) error {
+	return reconnect.Run(ctx, c.reconnect, c.connectAndListen, func(error) bool {
+		c.resetFeeds()
+		return false
+	})
+}
+
+// resetFeeds clears the state of every subscription after a disconnect
+func (c *Client) resetFeeds() {
+	for _, sub := range c.subscriptions {
+		sub.reset()
+	}
+}
+
+// streamURL returns the combined stream URL for every subscribed stream
+func (c *Client) streamURL() string {
+	streams := make([]string, 0, len(c.streams))
+	for _, symbol := range c.order {
+		streams = append(streams, c.subscriptions[symbol].streams()...)
+	}
+	return c.url + "?streams=" + strings.Join(streams, "/")
+}
+
+// connectAndListen runs a single connection until it fails. The connection
+// counts as established once a message was received on it.
+func (c *Client) connectAndListen(ctx context.Context) (bool, error) {
+	// Establish a WebSocket connection, streams are selected in the URL
+	conn, err := wsconn.Dial(ctx, c.streamURL())
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// Binance pings every few minutes, which also proves the connection is alive
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(c.staleTimeout))
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(pongTimeout))
+		if errors.Is(err, websocket.ErrCloseSent) {
+			return nil
+		}
+		return err
+	})
+
+	// Read messages in a separate goroutine so snapshot responses can be
+	// applied on this one without blocking the stream
+	done := make(chan struct{})
+	defer close(done)
+	messages := make(chan []byte)
+	readErrors := make(chan error, 1)
+	go func() {
+		for {
+			message, err := conn.Read(c.staleTimeout)
+			if err != nil {
+				readErrors <- err
+				return
+			}
+			select {
+			case messages <- message:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	snapshots := make(chan snapshotResult)
+	established := false
+	for {
+		select {
+		case message := <-messages:
+			established = true
+			sub, fetch, err := c.handleMessage(message)
+			if err != nil {
+				log.Printf("%v, refetching %s snapshot", err, sub.symbol)
+			}
+			if fetch {
+				c.requestSnapshot(sub, snapshots, done)
+			}
+
+		case result := <-snapshots:
+			if result.err != nil {
+				log.Printf("Error fetching %s snapshot: %v", result.sub.symbol, result.err)
+				result.sub.snapshotFailed()
+				continue
+			}
+			if result.sub.ProcessSnapshot(result.snapshot) {
+				c.requestSnapshot(result.sub, snapshots, done)
+			}
+
+		case err := <-readErrors:
+			if wsconn.IsTimeout(err) {
+				log.Printf("No message received within %s, reconnecting", c.staleTimeout)
+			} else {
+				log.Println("Error reading message:", err)
+			}
+			return established, err
+		}
+	}
+}
+
+// requestSnapshot fetches a REST snapshot for the subscription in the
+// background and delivers it on results
+func (c *Client) requestSnapshot(sub *subscription, results chan<- snapshotResult, done <-chan struct{}) {
+	sub.fetching = true
+	go func() {
+		snapshot, err := c.fetchSnapshot(sub.symbol)
+		select {
+		case results <- snapshotResult{sub: sub, snapshot: snapshot, err: err}:
+		case <-done:
+		}
+	}()
+}
+
+// fetchSnapshot requests the current order book from the REST API
+func (c *Client) fetchSnapshot(symbol string) (DepthSnapshot, error) {
+	query := url.Values{}
+	query.Set("symbol", symbol)
+	query.Set("limit", fmt.Sprint(c.snapshotLimit))
+
+	var snapshot DepthSnapshot
+	resp, err := c.httpClient.Get(c.restURL + "/fapi/v1/depth?" + query.Encode())
+	if err != nil {
+		return snapshot, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return snapshot, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&snapshot)
+	return snapshot, err
+}
+
+// handleMessage routes a message to the subscription of its stream. It returns
+// the subscription, whether a snapshot should be requested for it and any
+// error from processing the message.
+func (c *Client) handleMessage(message []byte) (*subscription, bool, error) {
+	var msg StreamMessage
+
+	err := json.Unmarshal(message, &msg)
+	if err != nil {
+		log.Println("Error parsing stream message:", err)
+		return nil, false, nil
+	}
+
+	sub, ok := c.streams[msg.Stream]
+	if !ok {
+		return nil, false, nil
+	}
+
+	switch msg.Stream {
+	case sub.depthStream():
+		var update DepthUpdate
+		err := json.Unmarshal(msg.Data, &update)
+		if err != nil {
+			log.Println("Error parsing depth update:", err)
+			return sub, false, nil
+		}
+		fetch, err := sub.ProcessDepthUpdate(update)
+		return sub, fetch, err
+
+	case sub.tradeStream():
+		var trade AggTrade
+		err := json.Unmarshal(msg.Data, &trade)
+		if err != nil {
+			log.Println("Error parsing trade:", err)
+			return sub, false, nil
+		}
+		sub.ProcessAggTrade(trade)
+
+	case sub.tickerStream():
+		var ticker BookTicker
+		err := json.Unmarshal(msg.Data, &ticker)
+		if err != nil {
+			log.Println("Error parsing book ticker:", err)
+			return sub, false, nil
+		}
+		sub.ProcessBookTicker(ticker)
+	}
+	return sub, false, nil
+}
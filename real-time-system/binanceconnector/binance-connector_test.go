@@ -0,0 +1,245 @@
+package binanceconnector
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+	"github.com/369geofreeman/inventory-control/real-time-system/reconnect"
+	"github.com/gorilla/websocket"
+)
+
+// mockBinance serves recorded stream messages over WebSocket and recorded
+// REST snapshots, one per request in order
+type mockBinance struct {
+	t         *testing.T
+	server    *httptest.Server
+	messages  [][]byte
+	snapshots []string
+
+	mu               sync.Mutex
+	snapshotRequests int
+	streamQuery      string
+	connections      int
+}
+
+func newMockBinance(t *testing.T, messages int, snapshots ...string) *mockBinance {
+	m := &mockBinance{t: t, messages: readLines(t, "testdata/stream.jsonl")[:messages]}
+	for _, name := range snapshots {
+		data, err := os.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.snapshots = append(m.snapshots, string(data))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", m.serveStream)
+	mux.HandleFunc("/fapi/v1/depth", m.serveSnapshot)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockBinance) serveStream(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		m.t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	m.mu.Lock()
+	m.streamQuery = r.URL.Query().Get("streams")
+	m.connections++
+	m.mu.Unlock()
+
+	for _, message := range m.messages {
+		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			return
+		}
+	}
+	// Keep the connection open until the client goes away
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (m *mockBinance) serveSnapshot(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r.URL.Query().Get("symbol") != "BTCUSDT" || m.snapshotRequests >= len(m.snapshots) {
+		http.Error(w, "unexpected snapshot request", http.StatusBadRequest)
+		return
+	}
+	w.Write([]byte(m.snapshots[m.snapshotRequests]))
+	m.snapshotRequests++
+}
+
+func (m *mockBinance) client(t *testing.T, config Config) *Client {
+	config.Symbols = []string{"BTCUSDT"}
+	config.URL = "ws" + strings.TrimPrefix(m.server.URL, "http") + "/stream"
+	config.RESTURL = m.server.URL
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func readLines(t *testing.T, path string) [][]byte {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var lines [][]byte
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, append([]byte(nil), scanner.Bytes()...))
+	}
+	return lines
+}
+
+// waitFor polls the feed until the condition holds or the test times out
+func waitFor(t *testing.T, feed *marketdata.Feed, condition func(marketdata.MarketSnapshot) bool) marketdata.MarketSnapshot {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		snapshot := feed.Snapshot()
+		if condition(snapshot) {
+			return snapshot
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for feed, last snapshot %+v", snapshot)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDepthBootstrapFromSnapshot(t *testing.T) {
+	mock := newMockBinance(t, 5, "snapshot_100.json")
+	client := mock.client(t, Config{})
+	go client.connectAndListen(context.Background())
+
+	feed, _ := client.Feed("BTCUSDT")
+	snapshot := waitFor(t, feed, func(s marketdata.MarketSnapshot) bool {
+		return s.MidPrice == 100.6 && s.IsTradeReady && s.IsTickerReady
+	})
+
+	// 100.2 and 100.0 on the bid, 101.0 on the ask are within 1% of the mid
+	if snapshot.Liquidity != 2 {
+		t.Errorf("Expected liquidity 2, got %f", snapshot.Liquidity)
+	}
+	if snapshot.LastPrice != 0 {
+		t.Errorf("Expected no last price from book ticker, got %f", snapshot.LastPrice)
+	}
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if mock.streamQuery != "btcusdt@depth@100ms/btcusdt@aggTrade/btcusdt@bookTicker" {
+		t.Errorf("Unexpected streams %q", mock.streamQuery)
+	}
+}
+
+func TestDepthResyncOnGap(t *testing.T) {
+	mock := newMockBinance(t, 7, "snapshot_100.json", "snapshot_200.json")
+	client := mock.client(t, Config{})
+	go client.connectAndListen(context.Background())
+
+	// The gap at update 106 forces a second snapshot, after which only the
+	// event straddling update 200 is applied
+	feed, _ := client.Feed("BTCUSDT")
+	waitFor(t, feed, func(s marketdata.MarketSnapshot) bool {
+		return s.MidPrice == 200.75
+	})
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if mock.snapshotRequests != 2 {
+		t.Errorf("Expected 2 snapshot requests, got %d", mock.snapshotRequests)
+	}
+}
+
+func TestStaleConnectionWatchdog(t *testing.T) {
+	// The server never sends anything, so every connection goes stale
+	mock := newMockBinance(t, 0)
+	client := mock.client(t, Config{
+		StaleTimeout: 50 * time.Millisecond,
+		Reconnect:    reconnect.Config{InitialDelay: 10 * time.Millisecond, MaxAttempts: 2},
+	})
+
+	result := make(chan error, 1)
+	go func() { result <- client.Connect(context.Background()) }()
+	select {
+	case err := <-result:
+		if !errors.Is(err, reconnect.ErrGaveUp) {
+			t.Errorf("Expected ErrGaveUp, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the stale connections to be dropped")
+	}
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if mock.connections != 2 {
+		t.Errorf("Expected 2 connections, got %d", mock.connections)
+	}
+}
+
+func TestFeedResetOnDisconnect(t *testing.T) {
+	mock := newMockBinance(t, 5, "snapshot_100.json")
+	client := mock.client(t, Config{
+		StaleTimeout: 200 * time.Millisecond,
+		Reconnect:    reconnect.Config{MaxAttempts: 1},
+	})
+
+	result := make(chan error, 1)
+	go func() { result <- client.Connect(context.Background()) }()
+	feed, _ := client.Feed("BTCUSDT")
+	waitFor(t, feed, func(s marketdata.MarketSnapshot) bool {
+		return s.MidPrice == 100.6 && s.IsTradeReady && s.IsTickerReady
+	})
+
+	// The connection goes stale and every metric from before the gap is dropped
+	select {
+	case <-result:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the connection to be dropped")
+	}
+	s := feed.Snapshot()
+	if s.MidPrice != 0 || s.IsTradeReady || s.IsTickerReady || s.TradeVolume != 0 {
+		t.Errorf("Expected the feed to be reset after the disconnect, got %+v", s)
+	}
+}
+
+func TestFirstEventAfterSnapshot(t *testing.T) {
+	sub := newSubscription("BTCUSDT")
+	sub.fetching = true
+	sub.ProcessSnapshot(DepthSnapshot{
+		LastUpdateID: 100,
+		Bids:         [][]string{{"100", "1"}},
+		Asks:         [][]string{{"101", "1"}},
+	})
+
+	// An event starting after the snapshot means events were missed
+	fetch, err := sub.ProcessDepthUpdate(DepthUpdate{FirstUpdateID: 102, FinalUpdateID: 105, PrevFinalUpdateID: 101})
+	if err == nil || !fetch {
+		t.Errorf("Expected a sequence gap and a snapshot request, got %v, %v", fetch, err)
+	}
+	if sub.synced {
+		t.Error("Expected the subscription to be out of sync")
+	}
+}
@@ -0,0 +1,210 @@
+package binanceconnector
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+	"github.com/369geofreeman/inventory-control/real-time-system/orderbook"
+)
+
+const (
+	venue = "binance"
+
+	maxBufferedUpdates = 1000 // Depth events kept while waiting for a snapshot
+)
+
+// ErrSequenceGap is returned when a depth event does not follow the previous
+// one and the local book has to be rebuilt from a fresh REST snapshot
+var ErrSequenceGap = errors.New("order book sequence gap")
+
+// subscription holds the Binance specific state for a single symbol and the
+// feed its normalized events are published to
+type subscription struct {
+	symbol string
+	feed   *marketdata.Feed
+
+	// Sequencing state of the diff depth stream, following Binance's rules for
+	// managing a local order book. Events are buffered until a snapshot has
+	// been fetched and are only forwarded while synced.
+	synced       bool
+	firstEvent   bool // The next event is the first one after the snapshot
+	fetching     bool // A snapshot request is in flight
+	buffer       []DepthUpdate
+	lastUpdateID int64
+}
+
+func newSubscription(symbol string) *subscription {
+	return &subscription{
+		symbol: symbol,
+		feed:   marketdata.NewFeed(venue, symbol),
+	}
+}
+
+func (s *subscription) depthStream() string {
+	return strings.ToLower(s.symbol) + "@depth@100ms"
+}
+
+func (s *subscription) tradeStream() string {
+	return strings.ToLower(s.symbol) + "@aggTrade"
+}
+
+func (s *subscription) tickerStream() string {
+	return strings.ToLower(s.symbol) + "@bookTicker"
+}
+
+func (s *subscription) streams() []string {
+	return []string{s.depthStream(), s.tradeStream(), s.tickerStream()}
+}
+
+// ProcessBookTicker normalizes the best bid and ask and publishes them to the feed
+func (s *subscription) ProcessBookTicker(ticker BookTicker) {
+	s.feed.OnTicker(marketdata.Ticker{
+		Venue:        venue,
+		Symbol:       s.symbol,
+		BestBid:      parseFloat(ticker.BidPrice),
+		BestBidSize:  parseFloat(ticker.BidQty),
+		BestAsk:      parseFloat(ticker.AskPrice),
+		BestAskSize:  parseFloat(ticker.AskQty),
+		ExchangeTime: time.UnixMilli(ticker.TransactionTime),
+	})
+}
+
+// ProcessAggTrade normalizes an aggregate trade and publishes it to the feed
+func (s *subscription) ProcessAggTrade(trade AggTrade) {
+	side := marketdata.Buy
+	if trade.IsBuyerMaker {
+		side = marketdata.Sell
+	}
+
+	s.feed.OnTrade(marketdata.Trade{
+		Venue:        venue,
+		Symbol:       s.symbol,
+		Price:        parseFloat(trade.Price),
+		Size:         parseFloat(trade.Quantity),
+		Side:         side,
+		ExchangeTime: time.UnixMilli(trade.TradeTime),
+	})
+}
+
+// ProcessDepthUpdate checks the sequencing of a depth event and publishes it to
+// the feed. While no snapshot has been applied the event is buffered and true
+// is returned when a snapshot should be requested. ErrSequenceGap is returned
+// when the event does not follow the previous one.
+func (s *subscription) ProcessDepthUpdate(update DepthUpdate) (bool, error) {
+	if !s.synced {
+		s.buffer = append(s.buffer, update)
+		if len(s.buffer) > maxBufferedUpdates {
+			s.buffer = s.buffer[1:]
+		}
+		return !s.fetching, nil
+	}
+
+	if s.firstEvent {
+		// Drop events that are already included in the snapshot
+		if update.FinalUpdateID < s.lastUpdateID {
+			return false, nil
+		}
+		// The first event must straddle the snapshot's last update ID
+		if update.FirstUpdateID > s.lastUpdateID {
+			return s.invalidate(update), fmt.Errorf("%w: first event starts at %d after snapshot %d",
+				ErrSequenceGap, update.FirstUpdateID, s.lastUpdateID)
+		}
+		s.firstEvent = false
+	} else if update.PrevFinalUpdateID != s.lastUpdateID {
+		return s.invalidate(update), fmt.Errorf("%w: previous update ID %d, expected %d",
+			ErrSequenceGap, update.PrevFinalUpdateID, s.lastUpdateID)
+	}
+
+	s.lastUpdateID = update.FinalUpdateID
+	s.feed.OnBookUpdate(marketdata.BookUpdate{
+		Venue:        venue,
+		Symbol:       s.symbol,
+		Type:         marketdata.Delta,
+		Bids:         parseLevels(update.Bids),
+		Asks:         parseLevels(update.Asks),
+		ExchangeTime: time.UnixMilli(update.TransactionTime),
+	})
+	return false, nil
+}
+
+// ProcessSnapshot applies a REST snapshot and replays the buffered events on
+// top of it. It returns true when a new snapshot should be requested.
+func (s *subscription) ProcessSnapshot(snapshot DepthSnapshot) bool {
+	s.fetching = false
+	s.synced = true
+	s.firstEvent = true
+	s.lastUpdateID = snapshot.LastUpdateID
+
+	s.feed.OnBookUpdate(marketdata.BookUpdate{
+		Venue:        venue,
+		Symbol:       s.symbol,
+		Type:         marketdata.Snapshot,
+		Bids:         parseLevels(snapshot.Bids),
+		Asks:         parseLevels(snapshot.Asks),
+		ExchangeTime: time.UnixMilli(snapshot.TransactionTime),
+	})
+
+	buffered := s.buffer
+	s.buffer = nil
+	for i, update := range buffered {
+		if _, err := s.ProcessDepthUpdate(update); err != nil {
+			log.Printf("%v, refetching %s snapshot", err, s.symbol)
+			// Keep the events that have not been replayed yet
+			s.buffer = append(s.buffer, buffered[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotFailed allows a new snapshot to be requested by the next event
+func (s *subscription) snapshotFailed() {
+	s.fetching = false
+}
+
+// invalidate stops publishing order book metrics until a new snapshot has been
+// applied and buffers the event that could not be applied. It returns true so
+// callers can request the snapshot.
+func (s *subscription) invalidate(update DepthUpdate) bool {
+	s.synced = false
+	s.buffer = append(s.buffer[:0], update)
+	s.feed.OnBookInvalid(s.symbol)
+	return true
+}
+
+// reset discards all sequencing state and the feed, e.g. after a disconnect
+func (s *subscription) reset() {
+	s.synced = false
+	s.fetching = false
+	s.buffer = nil
+	s.feed.Reset()
+}
+
+// parseLevels converts Binance [price, quantity] string pairs into order book levels
+func parseLevels(raw [][]string) []orderbook.Level {
+	levels := make([]orderbook.Level, 0, len(raw))
+	for _, level := range raw {
+		if len(level) < 2 {
+			continue
+		}
+		levels = append(levels, orderbook.Level{
+			Price: parseFloat(level[0]),
+			Size:  parseFloat(level[1]),
+		})
+	}
+	return levels
+}
+
+func parseFloat(s string) float64 {
+	val, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		log.Println("Error parsing float:", err)
+		return 0
+	}
+	return val
+}
@@ -0,0 +1,58 @@
+package binanceconnector
+
+import "encoding/json"
+
+// StreamMessage wraps every message received on a combined stream
+type StreamMessage struct {
+	Stream string          `json:"stream"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// DepthUpdate represents a diff depth stream event
+type DepthUpdate struct {
+	EventType         string     `json:"e"`
+	EventTime         int64      `json:"E"`
+	TransactionTime   int64      `json:"T"`
+	Symbol            string     `json:"s"`
+	FirstUpdateID     int64      `json:"U"`
+	FinalUpdateID     int64      `json:"u"`
+	PrevFinalUpdateID int64      `json:"pu"` // Final update ID of the previous event
+	Bids              [][]string `json:"b"`
+	Asks              [][]string `json:"a"`
+}
+
+// DepthSnapshot represents the response of the REST order book endpoint
+type DepthSnapshot struct {
+	LastUpdateID    int64      `json:"lastUpdateId"`
+	EventTime       int64      `json:"E"`
+	TransactionTime int64      `json:"T"`
+	Bids            [][]string `json:"bids"`
+	Asks            [][]string `json:"asks"`
+}
+
+// AggTrade represents an aggregate trade stream event
+type AggTrade struct {
+	EventType    string `json:"e"`
+	EventTime    int64  `json:"E"`
+	Symbol       string `json:"s"`
+	AggTradeID   int64  `json:"a"`
+	Price        string `json:"p"`
+	Quantity     string `json:"q"`
+	FirstTradeID int64  `json:"f"`
+	LastTradeID  int64  `json:"l"`
+	TradeTime    int64  `json:"T"`
+	IsBuyerMaker bool   `json:"m"` // True when the seller was the aggressor
+}
+
+// BookTicker represents a best bid and ask stream event
+type BookTicker struct {
+	EventType       string `json:"e"`
+	UpdateID        int64  `json:"u"`
+	EventTime       int64  `json:"E"`
+	TransactionTime int64  `json:"T"`
+	Symbol          string `json:"s"`
+	BidPrice        string `json:"b"`
+	BidQty          string `json:"B"`
+	AskPrice        string `json:"a"`
+	AskQty          string `json:"A"`
+}
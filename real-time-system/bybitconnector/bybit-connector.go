@@ -1,134 +1,316 @@
 package bybitconnector
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/gorilla/websocket"
+	"github.com/369geofreeman/inventory-control/real-time-system/clock"
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+	"github.com/369geofreeman/inventory-control/real-time-system/wsconn"
 )
 
 const (
 	bybitWSURL = "wss://stream.bybit.com/v5/public/linear"
 
-	backoffFactor = 2 // Multiplier for each subsequent reconnection delay
+	defaultOrderBookDepth = 50
+
+	defaultAckTimeout = 10 * time.Second // Time allowed for subscriptions to be acknowledged
 )
 
-var (
-	reconnectDelay       = 5 // Delay in seconds before attempting a reconnection
-	maxReconnectAttempts = 5 // Maximum number of reconnection attempts
+var _ marketdata.Source = (*Client)(nil)
 
-	IsOrderBookReady bool = false
-	IsTradeReady     bool = false
-	IsTickerReady    bool = false
-)
+// Order book depths available on Bybit's linear public stream
+var validOrderBookDepths = map[int]bool{1: true, 50: true, 200: true, 500: true}
+
+// Config defines what a Client subscribes to
+type Config struct {
+	Symbols        []string // Symbols to subscribe to, e.g. "BTCUSDT", "ETHUSDT"
+	OrderBookDepth int      // One of 1, 50, 200 or 500, defaults to 50
+	URL            string   // WebSocket URL, defaults to Bybit's public linear stream
+
+	PingInterval time.Duration // Interval between pings, defaults to 20s
+	StaleTimeout time.Duration // Reconnect when no message arrives within this time, defaults to 60s
+	AckTimeout   time.Duration // Reconnect when subscriptions are not acknowledged within this time, defaults to 10s
 
-func ConnectToBybit() error {
-	attempts := 0
-	for attempts < maxReconnectAttempts {
-		err := connectAndListen()
-		if err == nil {
-			return nil // Exit if successful
+	Reconnect ReconnectConfig
+
+	// Recorder, if set, receives every raw frame with its local receive time
+	Recorder Recorder
+	// Clock used for local timestamps, defaults to the wall clock
+	Clock clock.Clock
+}
+
+// Recorder persists raw frames, e.g. *recorder.Recorder
+type Recorder interface {
+	Record(topic string, receivedAt time.Time, message []byte) error
+}
+
+// Client maintains a single WebSocket connection to Bybit and publishes the
+// normalized messages for every subscribed symbol to its Feed.
+// Client implements marketdata.Source.
+type Client struct {
+	url           string
+	subscriptions map[string]*subscription
+	order         []string // Symbols in the order they were configured
+
+	pingInterval time.Duration
+	staleTimeout time.Duration
+	ackTimeout   time.Duration
+	reconnect    ReconnectConfig
+	recorder     Recorder
+
+	nextReqID     uint64   // Last req_id sent, only used by the connection goroutine
+	replaySession *session // Session used by HandleRecorded
+
+	statsMu sync.Mutex
+	stats   Stats
+}
+
+// NewClient validates the config and creates a Feed for each symbol
+func NewClient(config Config) (*Client, error) {
+	if len(config.Symbols) == 0 {
+		return nil, errors.New("at least one symbol is required")
+	}
+
+	depth := config.OrderBookDepth
+	if depth == 0 {
+		depth = defaultOrderBookDepth
+	}
+	if !validOrderBookDepths[depth] {
+		return nil, fmt.Errorf("unsupported order book depth %d", depth)
+	}
+
+	url := config.URL
+	if url == "" {
+		url = bybitWSURL
+	}
+
+	c := &Client{
+		url:           url,
+		subscriptions: make(map[string]*subscription, len(config.Symbols)),
+		pingInterval:  config.PingInterval,
+		staleTimeout:  config.StaleTimeout,
+		ackTimeout:    config.AckTimeout,
+		reconnect:     config.Reconnect.WithDefaults(),
+		recorder:      config.Recorder,
+	}
+	if c.pingInterval <= 0 {
+		c.pingInterval = defaultPingInterval
+	}
+	if c.staleTimeout <= 0 {
+		c.staleTimeout = defaultStaleTimeout
+	}
+	if c.ackTimeout <= 0 {
+		c.ackTimeout = defaultAckTimeout
+	}
+	for _, symbol := range config.Symbols {
+		if _, ok := c.subscriptions[symbol]; ok {
+			return nil, fmt.Errorf("duplicate symbol %s", symbol)
+		}
+		sub := newSubscription(symbol, depth)
+		if config.Clock != nil {
+			sub.feed.SetClock(config.Clock)
 		}
+		c.subscriptions[symbol] = sub
+		c.order = append(c.order, symbol)
+	}
+	return c, nil
+}
 
-		log.Printf("Error connecting or listening: %v", err)
-		log.Printf("Attempt %d/%d. Reconnecting in %d seconds...", attempts+1, maxReconnectAttempts, reconnectDelay)
-		time.Sleep(time.Duration(reconnectDelay) * time.Second)
+// Venue returns the name of the venue
+func (c *Client) Venue() string {
+	return venue
+}
 
-		// Increase the delay for the next attempt, with a backoff factor
-		reconnectDelay *= backoffFactor
-		attempts++
+// Feed returns the feed for the given symbol
+func (c *Client) Feed(symbol string) (*marketdata.Feed, bool) {
+	sub, ok := c.subscriptions[symbol]
+	if !ok {
+		return nil, false
 	}
+	return sub.feed, true
+}
 
-	log.Println("Max reconnection attempts reached. Exiting...")
-	return errors.New("max reconnection attempts reached")
+// Feeds returns every feed in the order the symbols were configured
+func (c *Client) Feeds() []*marketdata.Feed {
+	feeds := make([]*marketdata.Feed, 0, len(c.order))
+	for _, symbol := range c.order {
+		feeds = append(feeds, c.subscriptions[symbol].feed)
+	}
+	return feeds
 }
 
-func connectAndListen() error {
+// connectAndListen runs a single connection until it fails. The returned
+// session is nil if the connection could not be established.
+func (c *Client) connectAndListen(ctx context.Context) (*session, error) {
 	// Establish a WebSocket connection
-	conn, _, err := websocket.DefaultDialer.Dial(bybitWSURL, nil)
+	conn, err := wsconn.Dial(ctx, c.url)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer conn.Close()
+	c.updateStats(func(s *Stats) { s.Connections++ })
 
-	// Subscribe to the necessary channels
-	channels := []string{
-		"orderbook.50.BTCUSDT",
-		"publicTrade.BTCUSDT",
-		"tickers.BTCUSDT",
-	}
+	sess := newSession(conn)
 
-	for _, channel := range channels {
-		err := conn.WriteJSON(map[string]interface{}{
-			"op":   "subscribe",
-			"args": []string{channel},
-		})
+	// Subscribe to the necessary channels, one request per symbol
+	for _, symbol := range c.order {
+		sub := c.subscriptions[symbol]
+		err := c.send(sess, "subscribe", sub.topics()...)
 		if err != nil {
-			log.Printf("Failed to subscribe to channel %s: %v", channel, err)
+			return sess, fmt.Errorf("failed to subscribe to channels for %s: %w", symbol, err)
 		}
 	}
 
-	maxReconnectAttempts = 5
+	// Keep the connection alive, a missing pong closes it
+	done := make(chan struct{})
+	defer close(done)
+	go c.heartbeat(conn, done)
 
 	// Listen for incoming messages
 	for {
-		_, message, err := conn.ReadMessage()
+		message, err := conn.Read(c.staleTimeout)
+		receivedAt := time.Now()
 		if err != nil {
-			log.Println("Error reading message:", err)
-			return err
+			if wsconn.IsTimeout(err) {
+				log.Printf("No message received within %s, reconnecting", c.staleTimeout)
+				c.updateStats(func(s *Stats) { s.StaleConnections++ })
+			} else {
+				log.Println("Error reading message:", err)
+			}
+			c.updateStats(func(s *Stats) {
+				s.LastDisconnect = time.Now()
+				s.LastDisconnectErr = err.Error()
+			})
+			return sess, err
+		}
+		c.updateStats(func(s *Stats) { s.LastMessage = time.Now() })
+
+		sub, err := c.handleMessage(sess, receivedAt, message)
+		switch {
+		case errors.Is(err, ErrSequenceGap):
+			// Resubscribing makes Bybit send a fresh snapshot to rebuild the book from
+			log.Printf("%v, resubscribing to %s", err, sub.orderBookTopic())
+			if err := c.resync(sess, sub); err != nil {
+				return sess, err
+			}
+		case err != nil:
+			return sess, err
+		}
+
+		if err := sess.checkTimeouts(c.ackTimeout); err != nil {
+			return sess, err
 		}
-		handleMessage(message)
 	}
 }
 
-func handleMessage(message []byte) {
+// handleMessage records a message and routes it to the subscription of the
+// symbol in its topic, returning it along with any error from processing the message
+func (c *Client) handleMessage(sess *session, receivedAt time.Time, message []byte) (*subscription, error) {
 	var topic struct {
-		Topic string `json:"topic"`
+		Topic   string `json:"topic"`
+		Op      string `json:"op"`
+		Success bool   `json:"success"`
+		RetMsg  string `json:"ret_msg"`
+		ReqID   string `json:"req_id"`
 	}
 
 	err := json.Unmarshal(message, &topic)
+	if c.recorder != nil {
+		name := topic.Topic
+		if name == "" && topic.Op != "" {
+			name = "op." + topic.Op
+		}
+		if err := c.recorder.Record(name, receivedAt, message); err != nil {
+			log.Println("Error recording message:", err)
+		}
+	}
 	if err != nil {
 		log.Println("Error parsing topic:", err)
-		return
+		return nil, nil
+	}
+
+	if topic.Op == "ping" && topic.RetMsg == "pong" {
+		if sess.conn != nil {
+			sess.conn.Pong()
+		}
+		c.updateStats(func(s *Stats) {
+			s.PongsReceived++
+			s.LastPong = time.Now()
+		})
+		return nil, nil
+	}
+
+	if topic.Op == "subscribe" || topic.Op == "unsubscribe" {
+		return nil, c.handleAck(sess, topic.ReqID, topic.Success, topic.RetMsg)
+	}
+
+	// Topics are of the form "<channel>.<symbol>" or "orderbook.<depth>.<symbol>"
+	i := strings.LastIndexByte(topic.Topic, '.')
+	if i < 0 {
+		c.unhandled(topic.Topic, topic.Op, message)
+		return nil, nil
+	}
+	sub, ok := c.subscriptions[topic.Topic[i+1:]]
+	if !ok {
+		c.unhandled(topic.Topic, topic.Op, message)
+		return nil, nil
 	}
 
 	switch topic.Topic {
-	case "orderbook.50.BTCUSDT":
+	case sub.orderBookTopic():
 		var orderBook OrderBookSnapshot
 
 		err := json.Unmarshal(message, &orderBook)
 		if err != nil {
 			log.Println("Error parsing order book:", err)
-			return
-		}
-		ProcessOrderBook(orderBook.Data)
-
-		if RecentTrades.Len() >= recentTradePeriod {
-			IsOrderBookReady = true
+			return sub, nil
 		}
+		return sub, sub.ProcessOrderBook(orderBook)
 
-	case "publicTrade.BTCUSDT":
+	case sub.tradeTopic():
 		var trade TradeData
 		err := json.Unmarshal(message, &trade)
 		if err != nil {
 			log.Println("Error parsing trade:", err)
-			return
+			return sub, nil
 		}
-		ProcessTrade(trade)
-		IsTradeReady = true
+		sub.ProcessTrade(trade)
 
-	case "tickers.BTCUSDT":
+	case sub.tickerTopic():
 		var ticker Ticker
 		err := json.Unmarshal(message, &ticker)
 		if err != nil {
-			fmt.Println(string(message))
 			log.Println("Error parsing ticker:", err)
-			return
+			return sub, nil
 		}
-		ProcessTicker(ticker)
-		IsTickerReady = true
+		sub.ProcessTicker(ticker)
+
+	default:
+		c.unhandled(topic.Topic, topic.Op, message)
+	}
+	return sub, nil
+}
+
+// HandleRecorded processes a previously recorded frame through the same path
+// as live messages. Requests such as resubscriptions can't be sent without a
+// connection, so sequence gaps only invalidate the book until the recording
+// contains the next snapshot.
+func (c *Client) HandleRecorded(receivedAt time.Time, message []byte) error {
+	if c.replaySession == nil {
+		c.replaySession = newSession(nil)
+	}
+
+	_, err := c.handleMessage(c.replaySession, receivedAt, message)
+	if errors.Is(err, ErrSequenceGap) {
+		log.Printf("%v, waiting for the next recorded snapshot", err)
+		return nil
 	}
+	return err
 }
@@ -0,0 +1,340 @@
+package bybitconnector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+	"github.com/369geofreeman/inventory-control/real-time-system/volatility"
+	"github.com/369geofreeman/inventory-control/real-time-system/wsconn"
+)
+
+// waitFor polls the feed until the condition holds or the test times out
+func waitFor(t *testing.T, feed *marketdata.Feed, condition func(marketdata.MarketSnapshot) bool) marketdata.MarketSnapshot {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		snapshot := feed.Snapshot()
+		if condition(snapshot) {
+			return snapshot
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for feed, last snapshot %+v", snapshot)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func midPriceIs(price float64) func(marketdata.MarketSnapshot) bool {
+	return func(s marketdata.MarketSnapshot) bool {
+		return math.Abs(s.MidPrice-price) < 1e-9
+	}
+}
+
+// snapshotAt returns a snapshot function whose n-th snapshot is centered
+// around 100*(n+1)
+func snapshotAt(topic string, n int) string {
+	base := float64(100 * (n + 1))
+	return bookMessage("snapshot", 1, int64(100*(n+1)),
+		[][2]string{{fmt.Sprint(base), "1"}},
+		[][2]string{{fmt.Sprint(base + 1), "1"}},
+	)
+}
+
+func TestMetricsEndToEnd(t *testing.T) {
+	mock := newMockBybit(t)
+	mock.snapshot = func(topic string, n int) string {
+		return bookMessage("snapshot", 1, 100,
+			[][2]string{{"100", "1"}, {"99.5", "2"}, {"90", "5"}},
+			[][2]string{{"101", "1"}, {"101.5", "3"}},
+		)
+	}
+	client := mock.client(t, Config{Reconnect: ReconnectConfig{MaxAttempts: 1}})
+	feed, _ := client.Feed("BTCUSDT")
+	feed.SetVolatilityEstimator(volatility.NewRealized(time.Second, 2))
+	go client.Connect(context.Background())
+
+	conn := mock.accept(t)
+	for i := 0; i < 50; i++ {
+		conn.send(tradeMessage("100.5"))
+	}
+	conn.send(tickerMessage("100.7"))
+	// Two one second returns of the unchanged mid-price complete the volatility window
+	conn.send(bookMessage("delta", 2, 101, nil, nil))
+	conn.send(bookMessage("delta", 3, 102, nil, nil))
+	conn.send(bookMessage("delta", 4, 103, [][2]string{{"100.5", "1"}, {"99.5", "0"}}, nil))
+
+	snapshot := waitFor(t, feed, func(s marketdata.MarketSnapshot) bool { return s.Ready() })
+
+	if snapshot.MidPrice != 100.75 {
+		t.Errorf("Expected mid price 100.75, got %f", snapshot.MidPrice)
+	}
+	// Bids 100.5 and 100, asks 101 and 101.5 are within 1% of the mid
+	if snapshot.Liquidity != 3 {
+		t.Errorf("Expected liquidity 3, got %f", snapshot.Liquidity)
+	}
+	// The 90 bid is outside the 5% band
+	if snapshot.OrderBookDepth != 2 {
+		t.Errorf("Expected order book depth 2, got %f", snapshot.OrderBookDepth)
+	}
+	if snapshot.LastPrice != 100.7 {
+		t.Errorf("Expected last price 100.7, got %f", snapshot.LastPrice)
+	}
+	if snapshot.Volatility != 0 {
+		t.Errorf("Expected zero volatility for a constant mid-price, got %f", snapshot.Volatility)
+	}
+
+	subscribes := mock.requestsFor("subscribe")
+	if len(subscribes) != 1 || len(subscribes[0].Args) != 3 || subscribes[0].ReqID == "" {
+		t.Errorf("Expected a single subscribe request for 3 topics with a req_id, got %+v", subscribes)
+	}
+}
+
+func TestEveryTradeInMessageIsProcessed(t *testing.T) {
+	mock := newMockBybit(t)
+	client := mock.client(t, Config{Reconnect: ReconnectConfig{MaxAttempts: 1}})
+	go client.Connect(context.Background())
+
+	conn := mock.accept(t)
+	conn.send(`{"topic":"publicTrade.BTCUSDT","type":"snapshot","ts":1700000001000,"data":[` +
+		`{"T":1700000000000,"s":"BTCUSDT","S":"Buy","v":"1","p":"100","BT":false},` +
+		`{"T":1700000000500,"s":"BTCUSDT","S":"Sell","v":"3","p":"98","BT":false},` +
+		`{"T":1700000000600,"s":"BTCUSDT","S":"Sell","v":"50","p":"90","BT":true},` +
+		`{"T":1700000001000,"s":"BTCUSDT","S":"Buy","v":"4","p":"101","BT":false}]}`)
+
+	feed, _ := client.Feed("BTCUSDT")
+	snapshot := waitFor(t, feed, func(s marketdata.MarketSnapshot) bool { return s.TradeVolume == 8 })
+
+	// The block trade is left out of the trade flow
+	if snapshot.BuyVolume != 5 || snapshot.SellVolume != 3 {
+		t.Errorf("Expected buy volume 5 and sell volume 3, got %f and %f", snapshot.BuyVolume, snapshot.SellVolume)
+	}
+	if expected := (100.0 + 294 + 404) / 8; math.Abs(snapshot.VWAP-expected) > 1e-9 {
+		t.Errorf("Expected VWAP %f, got %f", expected, snapshot.VWAP)
+	}
+	if snapshot.TradeRate != 2 {
+		t.Errorf("Expected 2 trades per second, got %f", snapshot.TradeRate)
+	}
+	if !snapshot.TradeTime.Equal(time.UnixMilli(1700000001000)) {
+		t.Errorf("Expected the trade time of the last trade, got %v", snapshot.TradeTime)
+	}
+}
+
+func TestResyncOnSequenceGap(t *testing.T) {
+	mock := newMockBybit(t)
+	mock.snapshot = snapshotAt
+	client := mock.client(t, Config{Reconnect: ReconnectConfig{MaxAttempts: 1}})
+	go client.Connect(context.Background())
+
+	conn := mock.accept(t)
+	feed, _ := client.Feed("BTCUSDT")
+	waitFor(t, feed, midPriceIs(100.5))
+
+	// Update 2 is missing, so the delta must not be applied and the book is
+	// rebuilt from the snapshot sent on resubscription
+	conn.send(bookMessage("delta", 3, 102, [][2]string{{"100.8", "1"}}, nil))
+	waitFor(t, feed, midPriceIs(200.5))
+
+	unsubscribes := mock.requestsFor("unsubscribe")
+	if len(unsubscribes) != 1 || unsubscribes[0].Args[0] != "orderbook.50.BTCUSDT" {
+		t.Errorf("Expected the order book to be unsubscribed once, got %+v", unsubscribes)
+	}
+	if stats := client.Stats(); stats.Connections != 1 {
+		t.Errorf("Expected resync without reconnecting, got %d connections", stats.Connections)
+	}
+}
+
+func TestSnapshotResetOnServiceRestart(t *testing.T) {
+	mock := newMockBybit(t)
+	mock.snapshot = snapshotAt
+	client := mock.client(t, Config{Reconnect: ReconnectConfig{MaxAttempts: 1}})
+	go client.Connect(context.Background())
+
+	conn := mock.accept(t)
+	feed, _ := client.Feed("BTCUSDT")
+	conn.send(bookMessage("delta", 2, 101, [][2]string{{"100.2", "1"}}, nil))
+	waitFor(t, feed, midPriceIs(100.6))
+
+	// A snapshot with u == 1 replaces the book and restarts sequencing
+	conn.send(bookMessage("snapshot", 1, 500, [][2]string{{"300", "1"}}, [][2]string{{"301", "1"}}))
+	conn.send(bookMessage("delta", 2, 501, nil, [][2]string{{"300.6", "1"}}))
+	waitFor(t, feed, midPriceIs(300.3))
+}
+
+func TestReconnectAfterDroppedConnection(t *testing.T) {
+	mock := newMockBybit(t)
+	mock.snapshot = snapshotAt
+	client := mock.client(t, Config{Reconnect: ReconnectConfig{MaxAttempts: 3}})
+	go client.Connect(context.Background())
+
+	conn := mock.accept(t)
+	feed, _ := client.Feed("BTCUSDT")
+	waitFor(t, feed, midPriceIs(100.5))
+
+	conn.drop()
+	mock.accept(t)
+	waitFor(t, feed, midPriceIs(200.5))
+
+	stats := client.Stats()
+	if stats.Connections != 2 {
+		t.Errorf("Expected 2 connections, got %d", stats.Connections)
+	}
+	if stats.LastDisconnectErr == "" {
+		t.Error("Expected the disconnect to be recorded")
+	}
+}
+
+func TestGiveUpAfterMaxAttempts(t *testing.T) {
+	mock := newMockBybit(t)
+	client := mock.client(t, Config{Reconnect: ReconnectConfig{MaxAttempts: 1}})
+
+	result := make(chan error, 1)
+	go func() { result <- client.Connect(context.Background()) }()
+
+	mock.accept(t).drop()
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrGaveUp) {
+			t.Fatalf("Expected ErrGaveUp, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for Connect to give up")
+	}
+
+	if stats := client.Stats(); stats.Connections != 1 {
+		t.Errorf("Expected exactly 1 attempt, got %d connections", stats.Connections)
+	}
+	select {
+	case <-mock.connections:
+		t.Error("Expected no reconnection after the only attempt failed")
+	default:
+	}
+}
+
+func TestRejectedSubscriptionIsReturned(t *testing.T) {
+	mock := newMockBybit(t)
+	mock.rejectTopics["orderbook.50.BTCUSDT"] = "error:handler not found,topic:orderbook.50.BTCUSDT"
+	client := mock.client(t, Config{})
+
+	result := make(chan error, 1)
+	go func() { result <- client.Connect(context.Background()) }()
+
+	select {
+	case err := <-result:
+		var subErr *SubscriptionError
+		if !errors.As(err, &subErr) {
+			t.Fatalf("Expected a SubscriptionError, got %v", err)
+		}
+		if subErr.RetMsg != "error:handler not found,topic:orderbook.50.BTCUSDT" {
+			t.Errorf("Unexpected ret_msg %q", subErr.RetMsg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for Connect to return")
+	}
+
+	if subscribes := mock.requestsFor("subscribe"); len(subscribes) != maxSubscribeAttempts {
+		t.Errorf("Expected %d subscribe attempts, got %d", maxSubscribeAttempts, len(subscribes))
+	}
+	if stats := client.Stats(); stats.RejectedRequests != maxSubscribeAttempts {
+		t.Errorf("Expected %d rejected requests, got %d", maxSubscribeAttempts, stats.RejectedRequests)
+	}
+}
+
+func TestSessionStaysEstablished(t *testing.T) {
+	client := newMockBybit(t).client(t, Config{})
+	sess := newSession(&wsconn.Conn{})
+	sess.pending["1"] = &request{op: "subscribe", topics: []string{"orderbook.50.BTCUSDT"}}
+	sess.pending["2"] = &request{op: "subscribe", topics: []string{"publicTrade.BTCUSDT"}}
+
+	client.handleAck(sess, "1", true, "")
+	if sess.established {
+		t.Fatal("Expected the session not to be established while a subscription is pending")
+	}
+	client.handleAck(sess, "2", true, "")
+	if !sess.established {
+		t.Fatal("Expected the session to be established once every subscription was acknowledged")
+	}
+
+	// A resync pending when the connection ends doesn't undo it
+	sess.pending["3"] = &request{op: "unsubscribe", topics: []string{"orderbook.50.BTCUSDT"}}
+	if !sess.established {
+		t.Error("Expected the session to stay established during a resync")
+	}
+}
+
+func TestMalformedAndUnhandledMessages(t *testing.T) {
+	mock := newMockBybit(t)
+	client := mock.client(t, Config{Reconnect: ReconnectConfig{MaxAttempts: 1}})
+	go client.Connect(context.Background())
+
+	conn := mock.accept(t)
+	conn.send(`{"topic":"orderbook.50.BTCUSDT",`)
+	conn.send(`{"topic":"kline.1.BTCUSDT","data":[]}`)
+	conn.send(`{"topic":"tickers.ETHUSDT","data":{}}`)
+	conn.send(`{"success":true,"ret_msg":"","req_id":"999","op":"subscribe"}`)
+	conn.send(tickerMessage("100.7"))
+
+	// The connection survives and later messages are still processed
+	feed, _ := client.Feed("BTCUSDT")
+	waitFor(t, feed, func(s marketdata.MarketSnapshot) bool { return s.LastPrice == 100.7 })
+
+	stats := client.Stats()
+	if stats.UnhandledMessages != 2 {
+		t.Errorf("Expected 2 unhandled messages, got %d", stats.UnhandledMessages)
+	}
+	if stats.UnhandledTopics["kline.1.BTCUSDT"] != 1 || stats.UnhandledTopics["tickers.ETHUSDT"] != 1 {
+		t.Errorf("Unexpected unhandled topics %v", stats.UnhandledTopics)
+	}
+	if stats.UnknownResponses != 1 {
+		t.Errorf("Expected 1 unknown response, got %d", stats.UnknownResponses)
+	}
+}
+
+func TestStaleConnectionWatchdog(t *testing.T) {
+	mock := newMockBybit(t)
+	client := mock.client(t, Config{
+		PingInterval: time.Hour,
+		StaleTimeout: 100 * time.Millisecond,
+		Reconnect:    ReconnectConfig{MaxAttempts: 3},
+	})
+	go client.Connect(context.Background())
+
+	// The server never sends anything after the acknowledgement
+	mock.accept(t)
+	mock.accept(t)
+
+	if stats := client.Stats(); stats.StaleConnections == 0 {
+		t.Error("Expected the silent connection to be counted as stale")
+	}
+}
+
+func TestMissedPongReconnects(t *testing.T) {
+	mock := newMockBybit(t)
+	client := mock.client(t, Config{
+		PingInterval: 50 * time.Millisecond,
+		Reconnect:    ReconnectConfig{MaxAttempts: 3},
+	})
+	go client.Connect(context.Background())
+
+	mock.accept(t)
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Stats().PongsReceived == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for a pong")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mock.mu.Lock()
+	mock.silent = true
+	mock.mu.Unlock()
+	mock.accept(t)
+
+	if stats := client.Stats(); stats.MissedPongs == 0 {
+		t.Error("Expected the missing pong to be counted")
+	}
+}
@@ -1,111 +1,179 @@
 package bybitconnector
 
 import (
-	"container/list" // For using list as a deque
+	"errors"
+	"fmt"
 	"log"
-	"math"
 	"strconv"
-	"sync"
+	"time"
 
-	"github.com/369geofreeman/inventory-control/real-time-system/optimization"
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+	"github.com/369geofreeman/inventory-control/real-time-system/orderbook"
 )
 
-// Constants
-const recentTradePeriod = 50 // Number of recent trades to consider for volatility
-const liquidityRange = 0.01 // 0.5% of mid-price for liquidity calculation
+const venue = "bybit"
 
-// Data structures
-var (
-	MidPrice       float64
-	Volatility     float64
-	Liquidity      float64
-	OrderBookDepth float64
-	Mutex          sync.RWMutex
+// ErrSequenceGap is returned when an order book delta is missing or out of order
+// and the local book has to be rebuilt from a fresh snapshot
+var ErrSequenceGap = errors.New("order book sequence gap")
 
-	RecentTrades = list.New() // Deque to hold recent trades
+// subscription holds the Bybit specific state for a single symbol and the
+// feed its normalized events are published to
+type subscription struct {
+	symbol string
+	depth  int // Order book depth subscribed to, one of 1, 50, 200 or 500
+	feed   *marketdata.Feed
 
-)
+	// Sequencing state of the order book stream. Deltas are only forwarded
+	// while synced, i.e. every delta since the last snapshot arrived in order.
+	synced       bool
+	lastUpdateID int64
+	lastSeq      int64
+}
 
-// ProcessTicker processes the ticker data and updates the mid-price
-func ProcessTicker(ticker Ticker) {
-	Mutex.Lock()
-	defer Mutex.Unlock()
+func newSubscription(symbol string, depth int) *subscription {
+	return &subscription{
+		symbol: symbol,
+		depth:  depth,
+		feed:   marketdata.NewFeed(venue, symbol),
+	}
+}
 
-	bidPrice := parseFloat(ticker.Data.Bid1Price)
-	askPrice := parseFloat(ticker.Data.Ask1Price)
+func (s *subscription) orderBookTopic() string {
+	return fmt.Sprintf("orderbook.%d.%s", s.depth, s.symbol)
+}
 
-	MidPrice = (bidPrice + askPrice) / 2
+func (s *subscription) tradeTopic() string {
+	return "publicTrade." + s.symbol
+}
 
-	// log.Printf("MidPrice: %f", MidPrice)
+func (s *subscription) tickerTopic() string {
+	return "tickers." + s.symbol
 }
 
-// ProcessTrade processes trade data and updates the volatility
-func ProcessTrade(trade TradeData) {
-	Mutex.Lock()
-	defer Mutex.Unlock()
+func (s *subscription) topics() []string {
+	return []string{s.orderBookTopic(), s.tradeTopic(), s.tickerTopic()}
+}
 
-	// Push the new trade into the RecentTrades deque
-	price := parseFloat(trade.Data[0].Price)
-	RecentTrades.PushFront(price)
+// ProcessTicker normalizes the ticker data and publishes it to the feed.
+// Bybit only sends changed fields in ticker deltas, so empty fields are left at zero.
+func (s *subscription) ProcessTicker(ticker Ticker) {
+	s.feed.OnTicker(marketdata.Ticker{
+		Venue:        venue,
+		Symbol:       s.symbol,
+		LastPrice:    parseOptionalFloat(ticker.Data.LastPrice),
+		MarkPrice:    parseOptionalFloat(ticker.Data.MarkPrice),
+		IndexPrice:   parseOptionalFloat(ticker.Data.IndexPrice),
+		BestBid:      parseOptionalFloat(ticker.Data.Bid1Price),
+		BestBidSize:  parseOptionalFloat(ticker.Data.Bid1Size),
+		BestAsk:      parseOptionalFloat(ticker.Data.Ask1Price),
+		BestAskSize:  parseOptionalFloat(ticker.Data.Ask1Size),
+		ExchangeTime: time.UnixMilli(ticker.Timestamp),
+	})
+}
 
-	// Remove the oldest trade if we have more than our period
-	if RecentTrades.Len() > recentTradePeriod {
-		RecentTrades.Remove(RecentTrades.Back())
-	}
+// ProcessTrade normalizes every trade in the message and publishes them to
+// the feed in order. Bybit batches the fills of a match into one message.
+func (s *subscription) ProcessTrade(trade TradeData) {
+	for _, data := range trade.Data {
+		side := marketdata.Buy
+		if data.Direction == "Sell" {
+			side = marketdata.Sell
+		}
 
-	// Calculate volatility as the standard deviation of recent trade prices
-	var sum float64
-	var sumOfSquares float64
-	for e := RecentTrades.Front(); e != nil; e = e.Next() {
-		val := e.Value.(float64)
-		sum += val
-		sumOfSquares += val * val
+		s.feed.OnTrade(marketdata.Trade{
+			Venue:        venue,
+			Symbol:       s.symbol,
+			Price:        parseFloat(data.Price),
+			Size:         parseFloat(data.Volume),
+			Side:         side,
+			BlockTrade:   data.BlockTrade,
+			ExchangeTime: time.UnixMilli(data.TradeTimestamp),
+		})
 	}
-	mean := sum / float64(RecentTrades.Len())
-	Volatility = math.Sqrt(sumOfSquares/float64(RecentTrades.Len()) - mean*mean)
-
-	optimization.AdjustEmaFactorBasedOnVolatility(Volatility)
-
-	// log.Printf("Volatility: %f", Volatility)
 }
 
-// ProcessOrderBook processes order book data to calculate liquidity and order book depth
-func ProcessOrderBook(orderBook OrderBookData) {
-	Mutex.Lock()
-	defer Mutex.Unlock()
-
-	// Calculate liquidity and order book depth
-	var liquidityBid, liquidityAsk float64
-	var depthBid, depthAsk int
+// ProcessOrderBook checks the sequencing of an order book snapshot or delta
+// and publishes it to the feed. It returns ErrSequenceGap when a delta does
+// not follow the previous update, after which deltas are dropped until the
+// next snapshot arrives.
+func (s *subscription) ProcessOrderBook(message OrderBookSnapshot) error {
+	update := marketdata.BookUpdate{
+		Venue:        venue,
+		Symbol:       s.symbol,
+		Bids:         parseLevels(message.Data.Bids),
+		Asks:         parseLevels(message.Data.Asks),
+		ExchangeTime: time.UnixMilli(message.Timestamp),
+	}
 
-	for _, bid := range orderBook.Bids {
-		price := parseFloat(bid[0])
-		quantity := parseFloat(bid[1])
+	switch message.Type {
+	case "snapshot":
+		// A snapshot with u == 1 means Bybit restarted the service, any
+		// snapshot replaces the local book so both cases are handled the same way
+		if message.Data.UpdateID == 1 && s.lastUpdateID > 1 {
+			log.Printf("%s order book snapshot with update ID 1, service was restarted", s.symbol)
+		}
+		update.Type = marketdata.Snapshot
+		s.synced = true
 
-		if price >= MidPrice*(1-liquidityRange) {
-			liquidityBid += quantity
+	case "delta":
+		if !s.synced {
+			// Waiting for a fresh snapshot
+			return nil
 		}
-		if price >= MidPrice*0.95 { // considering 5% below mid-price for depth
-			depthBid++
+		if message.Data.UpdateID != s.lastUpdateID+1 || message.Data.Seq < s.lastSeq {
+			s.invalidate()
+			return fmt.Errorf("%w: update ID %d (seq %d) after %d (seq %d)",
+				ErrSequenceGap, message.Data.UpdateID, message.Data.Seq, s.lastUpdateID, s.lastSeq)
 		}
+		update.Type = marketdata.Delta
+
+	default:
+		log.Println("Unknown order book message type:", message.Type)
+		return nil
 	}
 
-	for _, ask := range orderBook.Asks {
-		price := parseFloat(ask[0])
-		quantity := parseFloat(ask[1])
+	s.lastUpdateID = message.Data.UpdateID
+	s.lastSeq = message.Data.Seq
+	s.feed.OnBookUpdate(update)
+	return nil
+}
 
-		if price <= MidPrice*(1+liquidityRange) {
-			liquidityAsk += quantity
-		}
-		if price <= MidPrice*1.05 { // considering 5% above mid-price for depth
-			depthAsk++
+// invalidate stops publishing order book metrics until the next snapshot
+func (s *subscription) invalidate() {
+	s.synced = false
+	s.feed.OnBookInvalid(s.symbol)
+}
+
+// reset discards all sequencing and market data state, e.g. after a disconnect
+func (s *subscription) reset() {
+	s.synced = false
+	s.lastUpdateID = 0
+	s.lastSeq = 0
+	s.feed.Reset()
+}
+
+// parseLevels converts Bybit [price, size] string pairs into order book levels
+func parseLevels(raw [][]string) []orderbook.Level {
+	levels := make([]orderbook.Level, 0, len(raw))
+	for _, level := range raw {
+		if len(level) < 2 {
+			continue
 		}
+		levels = append(levels, orderbook.Level{
+			Price: parseFloat(level[0]),
+			Size:  parseFloat(level[1]),
+		})
 	}
+	return levels
+}
 
-	Liquidity = (liquidityBid + liquidityAsk) / 2
-	OrderBookDepth = float64(depthBid+depthAsk) / 2
-
-	// log.Printf("Liquidity: %f, OrderBookDepth: %f", Liquidity, OrderBookDepth)
+// parseOptionalFloat parses a field that may be missing from a delta
+func parseOptionalFloat(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	return parseFloat(s)
 }
 
 func parseFloat(s string) float64 {
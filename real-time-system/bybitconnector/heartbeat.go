@@ -0,0 +1,68 @@
+package bybitconnector
+
+import (
+	"errors"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/wsconn"
+)
+
+const (
+	defaultPingInterval = 20 * time.Second // Bybit recommends a ping every 20 seconds
+	defaultStaleTimeout = 60 * time.Second // Reconnect if no message arrives within this time
+)
+
+// Stats counts connection events so they can be surfaced to monitoring
+type Stats struct {
+	Connections       int       // Successful dials
+	PingsSent         int       // Application level pings sent
+	PongsReceived     int       // Pong replies received
+	MissedPongs       int       // Connections torn down for a missing pong
+	StaleConnections  int       // Connections torn down for receiving no messages
+	LastPong          time.Time // Local time of the last pong
+	LastMessage       time.Time // Local time of the last message of any kind
+	LastDisconnect    time.Time // Local time the last connection was torn down
+	LastDisconnectErr string    // Reason the last connection was torn down
+
+	RejectedRequests  int            // Subscribe or unsubscribe requests rejected by Bybit
+	UnknownResponses  int            // Responses that matched no pending request
+	UnhandledMessages int            // Messages no handler recognized
+	UnhandledTopics   map[string]int // Unhandled messages by topic, or by op if there is no topic
+}
+
+// Stats returns a copy of the connection statistics
+func (c *Client) Stats() Stats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
+	stats := c.stats
+	if c.stats.UnhandledTopics != nil {
+		stats.UnhandledTopics = make(map[string]int, len(c.stats.UnhandledTopics))
+		for topic, count := range c.stats.UnhandledTopics {
+			stats.UnhandledTopics[topic] = count
+		}
+	}
+	return stats
+}
+
+// updateStats applies a change to the statistics under the lock
+func (c *Client) updateStats(update func(*Stats)) {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	update(&c.stats)
+}
+
+// heartbeat sends Bybit's {"op":"ping"} every ping interval until done is
+// closed, closing the connection when a ping is not answered
+func (c *Client) heartbeat(conn *wsconn.Conn, done <-chan struct{}) {
+	err := conn.Heartbeat(c.pingInterval, func() error {
+		if err := conn.WriteJSON(map[string]string{"op": "ping"}); err != nil {
+			return err
+		}
+		c.updateStats(func(s *Stats) { s.PingsSent++ })
+		return nil
+	}, done)
+	if errors.Is(err, wsconn.ErrMissedPong) {
+		c.updateStats(func(s *Stats) { s.MissedPongs++ })
+	}
+}
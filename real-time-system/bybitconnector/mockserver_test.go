@@ -0,0 +1,219 @@
+package bybitconnector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// mockBybit is an in-process server speaking Bybit's v5 public protocol. It
+// acknowledges subscriptions, answers pings and hands every connection to the
+// test so it can stream scripted messages, inject gaps or drop the connection.
+type mockBybit struct {
+	t      *testing.T
+	server *httptest.Server
+
+	// snapshot returns the order book snapshot sent when a topic is
+	// subscribed, n counts the snapshots sent for the topic so far
+	snapshot func(topic string, n int) string
+
+	connections chan *mockConn
+
+	mu           sync.Mutex
+	rejectTopics map[string]string // Topics to reject with the given ret_msg
+	requests     []mockRequest
+	snapshots    map[string]int
+	silent       bool // Stop answering pings
+}
+
+type mockRequest struct {
+	ReqID string   `json:"req_id"`
+	Op    string   `json:"op"`
+	Args  []string `json:"args"`
+}
+
+// mockConn is a single connection accepted by the mock server
+type mockConn struct {
+	ws         *websocket.Conn
+	writeMu    sync.Mutex
+	subscribed chan struct{} // Closed once the first subscribe request was acknowledged
+	once       sync.Once
+}
+
+func newMockBybit(t *testing.T) *mockBybit {
+	m := &mockBybit{
+		t:            t,
+		connections:  make(chan *mockConn, 10),
+		rejectTopics: make(map[string]string),
+		snapshots:    make(map[string]int),
+	}
+	m.server = httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// url returns the WebSocket URL of the server
+func (m *mockBybit) url() string {
+	return "ws" + strings.TrimPrefix(m.server.URL, "http")
+}
+
+// client creates a client connected to the mock with short timeouts
+func (m *mockBybit) client(t *testing.T, config Config) *Client {
+	config.URL = m.url()
+	if len(config.Symbols) == 0 {
+		config.Symbols = []string{"BTCUSDT"}
+	}
+	if config.Reconnect.InitialDelay == 0 {
+		config.Reconnect.InitialDelay = 10 * time.Millisecond
+	}
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// accept waits for the next connection to the server
+func (m *mockBybit) accept(t *testing.T) *mockConn {
+	select {
+	case conn := <-m.connections:
+		select {
+		case <-conn.subscribed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for subscription")
+		}
+		return conn
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a connection")
+	}
+	return nil
+}
+
+// requestsFor returns every request received with the given op
+func (m *mockBybit) requestsFor(op string) []mockRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var requests []mockRequest
+	for _, req := range m.requests {
+		if req.Op == op {
+			requests = append(requests, req)
+		}
+	}
+	return requests
+}
+
+func (m *mockBybit) serve(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		m.t.Error(err)
+		return
+	}
+	conn := &mockConn{ws: ws, subscribed: make(chan struct{})}
+	defer ws.Close()
+	m.connections <- conn
+
+	for {
+		_, message, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var req mockRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			m.t.Errorf("Invalid request %s: %v", message, err)
+			return
+		}
+		m.mu.Lock()
+		m.requests = append(m.requests, req)
+		silent := m.silent
+		m.mu.Unlock()
+
+		switch req.Op {
+		case "ping":
+			if !silent {
+				conn.send(`{"success":true,"ret_msg":"pong","conn_id":"mock","op":"ping"}`)
+			}
+		case "subscribe":
+			m.subscribe(conn, req)
+		case "unsubscribe":
+			conn.send(fmt.Sprintf(`{"success":true,"ret_msg":"","conn_id":"mock","req_id":%q,"op":"unsubscribe"}`, req.ReqID))
+		}
+	}
+}
+
+// subscribe acknowledges or rejects a subscribe request and sends a
+// snapshot for every order book topic in it
+func (m *mockBybit) subscribe(conn *mockConn, req mockRequest) {
+	m.mu.Lock()
+	var retMsg string
+	for _, topic := range req.Args {
+		if msg, ok := m.rejectTopics[topic]; ok {
+			retMsg = msg
+		}
+	}
+	m.mu.Unlock()
+
+	if retMsg != "" {
+		conn.send(fmt.Sprintf(`{"success":false,"ret_msg":%q,"conn_id":"mock","req_id":%q,"op":"subscribe"}`, retMsg, req.ReqID))
+		return
+	}
+	conn.send(fmt.Sprintf(`{"success":true,"ret_msg":"","conn_id":"mock","req_id":%q,"op":"subscribe"}`, req.ReqID))
+
+	for _, topic := range req.Args {
+		if !strings.HasPrefix(topic, "orderbook.") || m.snapshot == nil {
+			continue
+		}
+		m.mu.Lock()
+		n := m.snapshots[topic]
+		m.snapshots[topic]++
+		m.mu.Unlock()
+		conn.send(m.snapshot(topic, n))
+	}
+	conn.once.Do(func() { close(conn.subscribed) })
+}
+
+// send writes a raw frame to the client
+func (c *mockConn) send(message string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.WriteMessage(websocket.TextMessage, []byte(message))
+}
+
+// drop closes the connection without a close frame
+func (c *mockConn) drop() {
+	c.ws.UnderlyingConn().Close()
+}
+
+// bookMessage builds an orderbook message for BTCUSDT at depth 50,
+// timestamped seq seconds after a fixed epoch
+func bookMessage(typ string, updateID, seq int64, bids, asks [][2]string) string {
+	return fmt.Sprintf(`{"topic":"orderbook.50.BTCUSDT","type":%q,"ts":%d,"data":{"s":"BTCUSDT","b":%s,"a":%s,"u":%d,"seq":%d}}`,
+		typ, 1700000000000+seq*1000, levels(bids), levels(asks), updateID, seq)
+}
+
+// tradeMessage builds a publicTrade message with a single trade
+func tradeMessage(price string) string {
+	return fmt.Sprintf(`{"topic":"publicTrade.BTCUSDT","type":"snapshot","ts":1700000000000,"data":[{"T":1700000000000,"s":"BTCUSDT","S":"Buy","v":"0.01","p":%q,"BT":false}]}`, price)
+}
+
+// tickerMessage builds a tickers message with the last price
+func tickerMessage(lastPrice string) string {
+	return fmt.Sprintf(`{"topic":"tickers.BTCUSDT","type":"snapshot","ts":1700000000000,"data":{"symbol":"BTCUSDT","lastPrice":%q}}`, lastPrice)
+}
+
+func levels(raw [][2]string) string {
+	data, _ := json.Marshal(raw)
+	if raw == nil {
+		return "[]"
+	}
+	return string(data)
+}
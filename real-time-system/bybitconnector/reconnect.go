@@ -0,0 +1,49 @@
+package bybitconnector
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/reconnect"
+)
+
+// ErrGaveUp is returned by Connect once MaxAttempts consecutive connections have failed
+var ErrGaveUp = reconnect.ErrGaveUp
+
+// ReconnectConfig controls how the client reconnects after a connection
+// fails. A session is established once every subscription was first
+// acknowledged, even if a later resync is pending when it ends.
+type ReconnectConfig = reconnect.Config
+
+// Connect connects to Bybit and processes messages for every feed until ctx
+// is cancelled, it gives up or a subscription is rejected, in which case a *SubscriptionError
+// is returned. Whenever a connection ends every feed is reset, so consumers see
+// the market data as not ready and can pull their quotes until the next
+// session has rebuilt the books.
+func (c *Client) Connect(ctx context.Context) error {
+	return reconnect.Run(ctx, c.reconnect,
+		func(ctx context.Context) (bool, error) {
+			sess, err := c.connectAndListen(ctx)
+			return sess != nil && sess.established, err
+		},
+		func(err error) bool {
+			c.resetFeeds()
+
+			// A rejected subscription is a configuration problem that reconnecting won't fix
+			var subErr *SubscriptionError
+			if errors.As(err, &subErr) {
+				log.Println("Error subscribing:", err)
+				return true
+			}
+			return false
+		},
+	)
+}
+
+// resetFeeds clears the state of every subscription after a disconnect
+func (c *Client) resetFeeds() {
+	for _, sub := range c.subscriptions {
+		sub.reset()
+	}
+}
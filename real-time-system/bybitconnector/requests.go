@@ -0,0 +1,160 @@
+package bybitconnector
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/wsconn"
+)
+
+const maxSubscribeAttempts = 3 // Attempts for a rejected subscription before giving up
+
+// SubscriptionError is returned when Bybit keeps rejecting a subscription,
+// which usually means the topic is misconfigured. Reconnecting won't fix it,
+// so Connect returns it instead of retrying.
+type SubscriptionError struct {
+	Topics []string
+	RetMsg string // Reason given by Bybit
+}
+
+func (e *SubscriptionError) Error() string {
+	return fmt.Sprintf("subscription to %s rejected: %s", strings.Join(e.Topics, ", "), e.RetMsg)
+}
+
+// request is a subscribe or unsubscribe request waiting for its response
+type request struct {
+	op       string
+	topics   []string
+	sent     time.Time
+	attempts int
+}
+
+// session holds the state of a single connection
+type session struct {
+	conn    *wsconn.Conn        // nil when replaying recorded messages
+	pending map[string]*request // Requests waiting for a response by req_id
+
+	// Set once every request was first answered, and kept when a later
+	// resync or retry is still pending as the connection ends
+	established bool
+}
+
+func newSession(conn *wsconn.Conn) *session {
+	return &session{
+		conn:    conn,
+		pending: make(map[string]*request),
+	}
+}
+
+// answered marks the session established once no request is pending
+func (s *session) answered() {
+	if len(s.pending) == 0 {
+		s.established = true
+	}
+}
+
+// checkTimeouts returns an error if any request has waited longer than timeout
+func (s *session) checkTimeouts(timeout time.Duration) error {
+	for reqID, req := range s.pending {
+		if time.Since(req.sent) > timeout {
+			return fmt.Errorf("%s request %s for %s not acknowledged within %s",
+				req.op, reqID, strings.Join(req.topics, ", "), timeout)
+		}
+	}
+	return nil
+}
+
+// send sends a subscribe or unsubscribe request for the given topics and
+// tracks it until the response arrives
+func (c *Client) send(sess *session, op string, topics ...string) error {
+	return c.sendRequest(sess, &request{op: op, topics: topics})
+}
+
+func (c *Client) sendRequest(sess *session, req *request) error {
+	c.nextReqID++
+	reqID := strconv.FormatUint(c.nextReqID, 10)
+
+	err := sess.conn.WriteJSON(map[string]interface{}{
+		"req_id": reqID,
+		"op":     req.op,
+		"args":   req.topics,
+	})
+	if err != nil {
+		return err
+	}
+
+	req.sent = time.Now()
+	req.attempts++
+	sess.pending[reqID] = req
+	return nil
+}
+
+// resync resubscribes to an order book channel so a new snapshot is sent
+func (c *Client) resync(sess *session, sub *subscription) error {
+	if err := c.send(sess, "unsubscribe", sub.orderBookTopic()); err != nil {
+		return err
+	}
+	return c.send(sess, "subscribe", sub.orderBookTopic())
+}
+
+// handleAck matches a subscribe or unsubscribe response to its request.
+// Rejected subscriptions are retried and a SubscriptionError is returned once
+// they have failed maxSubscribeAttempts times.
+func (c *Client) handleAck(sess *session, reqID string, success bool, retMsg string) error {
+	if sess.conn == nil {
+		// Responses in a replay belong to requests of the recorded session
+		return nil
+	}
+
+	req, ok := sess.pending[reqID]
+	if !ok {
+		log.Printf("Response for unknown request %q: success=%t %s", reqID, success, retMsg)
+		c.updateStats(func(s *Stats) { s.UnknownResponses++ })
+		return nil
+	}
+	delete(sess.pending, reqID)
+
+	if success {
+		sess.answered()
+		return nil
+	}
+
+	log.Printf("Bybit rejected %s request %s for %s: %s", req.op, reqID, strings.Join(req.topics, ", "), retMsg)
+	c.updateStats(func(s *Stats) { s.RejectedRequests++ })
+
+	if req.op != "subscribe" {
+		// A failed unsubscribe leaves the topic subscribed, which is harmless
+		sess.answered()
+		return nil
+	}
+	if req.attempts >= maxSubscribeAttempts {
+		return &SubscriptionError{Topics: req.topics, RetMsg: retMsg}
+	}
+	return c.sendRequest(sess, req)
+}
+
+// unhandled counts and logs a message that no handler recognized. Each topic
+// is only logged the first time it is seen to keep the log readable.
+func (c *Client) unhandled(topic, op string, message []byte) {
+	key := topic
+	if key == "" {
+		key = "op:" + op
+	}
+
+	var first bool
+	c.updateStats(func(s *Stats) {
+		s.UnhandledMessages++
+		if s.UnhandledTopics == nil {
+			s.UnhandledTopics = make(map[string]int)
+		}
+		s.UnhandledTopics[key]++
+		first = s.UnhandledTopics[key] == 1
+	})
+
+	if first {
+		log.Printf("Unhandled message for %q: %.200s", key, message)
+	}
+}
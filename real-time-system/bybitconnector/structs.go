@@ -1,14 +1,16 @@
 package bybitconnector
 
-// OrderBookSnapshot represents the full snapshot of the order book
+// OrderBookSnapshot represents an order book message. Type is either
+// "snapshot" for the full book or "delta" for changed levels only.
 type OrderBookSnapshot struct {
 	Topic     string        `json:"topic"`
-	Type      string        `json:"type"`
+	Type      string        `json:"type"` // "snapshot" or "delta"
 	Timestamp int64         `json:"ts"`
 	Data      OrderBookData `json:"data"`
 }
 
-// OrderBookData represents the order book data within the snapshot
+// OrderBookData represents the order book data within the message.
+// In a delta a level with a size of "0" should be removed from the book.
 type OrderBookData struct {
 	Symbol   string     `json:"s"`
 	Bids     [][]string `json:"b"`
@@ -28,7 +30,7 @@ type TradeData struct {
 		Direction      string `json:"S"`
 		Volume         string `json:"v"`
 		Price          string `json:"p"`
-		Liquidation    bool   `json:"BT"`
+		BlockTrade     bool   `json:"BT"`
 	} `json:"data"`
 }
 
@@ -57,7 +59,7 @@ type TickerData struct {
 	OpenInterestValue string `json:"openInterestValue"`
 	Turnover24h       string `json:"turnover24h"`
 	Volume24h         string `json:"volume24h"`
-	NextFundingTime   string  `json:"nextFundingTime"`
+	NextFundingTime   string `json:"nextFundingTime"`
 	FundingRate       string `json:"fundingRate"`
 	Bid1Price         string `json:"bid1Price"`
 	Bid1Size          string `json:"bid1Size"`
@@ -0,0 +1,132 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time. Live components use Real, replays use a Simulated
+// clock so timestamps follow the recording instead of the wall clock.
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns a timer that fires once the clock has moved d ahead
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer delivers the time on C once it expires, like time.Timer
+type Timer interface {
+	C() <-chan time.Time
+	// Stop prevents the timer from firing and reports whether it was still
+	// pending
+	Stop() bool
+}
+
+// Real is the wall clock
+type Real struct{}
+
+// Now returns the current local time
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer returns a wall clock timer
+func (Real) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// realTimer adapts time.Timer to Timer
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// Simulated is a clock that only moves when it is set.
+// Simulated is safe for concurrent use.
+type Simulated struct {
+	mu     sync.RWMutex
+	now    time.Time
+	timers map[*simulatedTimer]struct{} // Pending timers
+}
+
+// NewSimulated creates a simulated clock starting at the given time
+func NewSimulated(start time.Time) *Simulated {
+	return &Simulated{now: start, timers: make(map[*simulatedTimer]struct{})}
+}
+
+// Now returns the simulated time
+func (s *Simulated) Now() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.now
+}
+
+// NewTimer returns a timer that fires when the clock is set or advanced to d
+// past the current simulated time
+func (s *Simulated) NewTimer(d time.Duration) Timer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := &simulatedTimer{clock: s, at: s.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- s.now
+		return t
+	}
+	if s.timers == nil {
+		s.timers = make(map[*simulatedTimer]struct{})
+	}
+	s.timers[t] = struct{}{}
+	return t
+}
+
+// Set moves the clock to the given time. Moving backwards is ignored so the
+// clock stays monotonic.
+func (s *Simulated) Set(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.After(s.now) {
+		s.now = t
+		s.fire()
+	}
+}
+
+// Advance moves the clock forward by d
+func (s *Simulated) Advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d > 0 {
+		s.now = s.now.Add(d)
+		s.fire()
+	}
+}
+
+// fire expires every pending timer that is due at the current time
+func (s *Simulated) fire() {
+	for t := range s.timers {
+		if !t.at.After(s.now) {
+			t.c <- s.now
+			delete(s.timers, t)
+		}
+	}
+}
+
+// simulatedTimer is a pending timer of a Simulated clock
+type simulatedTimer struct {
+	clock *Simulated
+	at    time.Time
+	c     chan time.Time // Buffered so firing never blocks the clock
+}
+
+func (t *simulatedTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *simulatedTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	_, pending := t.clock.timers[t]
+	delete(t.clock.timers, t)
+	return pending
+}
@@ -0,0 +1,49 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+// fired reports whether the timer has fired without waiting
+func fired(t Timer) bool {
+	select {
+	case <-t.C():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestSimulatedTimer(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewSimulated(start)
+
+	timer := s.NewTimer(time.Second)
+	s.Advance(500 * time.Millisecond)
+	if fired(timer) {
+		t.Fatal("Expected the timer not to fire before it is due")
+	}
+	s.Set(start.Add(time.Second))
+	if !fired(timer) {
+		t.Fatal("Expected the timer to fire once the clock reached it")
+	}
+	if timer.Stop() {
+		t.Error("Expected stopping a fired timer to report it was not pending")
+	}
+
+	// A stopped timer never fires
+	timer = s.NewTimer(time.Second)
+	if !timer.Stop() {
+		t.Error("Expected stopping a pending timer to report it was pending")
+	}
+	s.Advance(time.Hour)
+	if fired(timer) {
+		t.Error("Expected a stopped timer not to fire")
+	}
+
+	// A timer that is already due fires immediately
+	if !fired(s.NewTimer(0)) {
+		t.Error("Expected a timer without a duration to fire immediately")
+	}
+}
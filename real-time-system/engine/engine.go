@@ -0,0 +1,201 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/clock"
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+)
+
+// Quoter places and pulls quotes. The engine calls it from a single goroutine.
+type Quoter interface {
+	// Quote re-quotes around the given market state
+	Quote(snapshot marketdata.MarketSnapshot)
+	// Pull cancels any resting quotes, e.g. while market data is unavailable
+	Pull()
+}
+
+// Config controls how often the engine re-quotes
+type Config struct {
+	TickSize float64 // Price increment of the symbol, required
+
+	MinInterval time.Duration // Minimum time between two quotes, 100ms by default, negative for none
+	MaxInterval time.Duration // Re-quote at least this often, 1 minute by default
+
+	// Re-quote before the interval elapses once the fair value has moved by at
+	// least this many ticks since the last quote, 1 by default. Negative
+	// re-quotes on every update.
+	PriceMoveTicks float64
+
+	// The re-quote interval shrinks linearly from MaxInterval to MinInterval
+	// as annualized volatility rises from LowVolatility to HighVolatility,
+	// e.g. 0.5 for 50%. Both 0 disables volatility-dependent cadence.
+	LowVolatility  float64
+	HighVolatility float64
+
+	Clock clock.Clock // Clock to throttle with, the wall clock by default
+}
+
+// withDefaults returns the config with zero values replaced by defaults.
+// A zero value can't be told apart from an unset one, so the throttles are
+// disabled with a negative value instead.
+func (c Config) withDefaults() Config {
+	if c.MinInterval == 0 {
+		c.MinInterval = 100 * time.Millisecond
+	} else if c.MinInterval < 0 {
+		c.MinInterval = 0
+	}
+	if c.MaxInterval == 0 {
+		c.MaxInterval = time.Minute
+	}
+	if c.PriceMoveTicks == 0 {
+		c.PriceMoveTicks = 1
+	} else if c.PriceMoveTicks < 0 {
+		c.PriceMoveTicks = 0
+	}
+	if c.Clock == nil {
+		c.Clock = clock.Real{}
+	}
+	return c
+}
+
+// validate checks the config after defaults have been applied
+func (c Config) validate() error {
+	if c.TickSize <= 0 {
+		return errors.New("tick size should be greater than 0")
+	}
+	if c.MaxInterval < c.MinInterval {
+		return errors.New("max interval should not be less than min interval")
+	}
+	if c.LowVolatility < 0 || c.HighVolatility < c.LowVolatility {
+		return errors.New("volatility thresholds should satisfy 0 <= low <= high")
+	}
+	return nil
+}
+
+// Engine re-quotes a single feed whenever its market data changes enough,
+// subject to the throttles in Config
+type Engine struct {
+	feed   *marketdata.Feed
+	quoter Quoter
+	config Config
+
+	quoting       bool      // Whether quotes are currently resting
+	lastQuote     time.Time // Time of the last quote
+	lastQuoteFair float64   // Fair value of the last quote
+}
+
+// New creates an engine quoting the given feed
+func New(feed *marketdata.Feed, quoter Quoter, config Config) (*Engine, error) {
+	config = config.withDefaults()
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &Engine{
+		feed:   feed,
+		quoter: quoter,
+		config: config,
+	}, nil
+}
+
+// Run re-quotes on feed updates until the context is cancelled. Quotes are
+// pulled before Run returns the context's error. Throttles expire on the
+// configured clock, so a replay throttles at the speed it is replayed.
+func (e *Engine) Run(ctx context.Context) error {
+	timer := e.config.Clock.NewTimer(0)
+	defer func() { timer.Stop() }()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if e.quoting {
+				e.pull()
+			}
+			return ctx.Err()
+		case <-e.feed.Updates():
+		case <-timer.C():
+		}
+
+		// Re-evaluate when the next throttle expires even if no update arrives
+		wait := e.Step(e.config.Clock.Now())
+		timer.Stop()
+		if wait > 0 {
+			timer = e.config.Clock.NewTimer(wait)
+		} else {
+			timer = stopped{}
+		}
+	}
+}
+
+// Step evaluates the feed once at now, quoting or pulling quotes like Run
+// does on an update, and returns how long until the next throttle expires,
+// 0 if nothing is due. Replays call Step after every frame instead of
+// running Run, so quotes follow the recording deterministically. Step must
+// not be called while Run is running.
+func (e *Engine) Step(now time.Time) time.Duration {
+	return e.evaluate(e.feed.Snapshot(), now)
+}
+
+// stopped is a timer that never fires, used while no throttle is pending
+type stopped struct{}
+
+func (stopped) C() <-chan time.Time { return nil }
+
+func (stopped) Stop() bool { return false }
+
+// evaluate quotes or pulls quotes for the given market state and returns how
+// long to wait before the throttles allow the next quote, 0 if nothing is due
+func (e *Engine) evaluate(snapshot marketdata.MarketSnapshot, now time.Time) time.Duration {
+	if !snapshot.Ready() {
+		if e.quoting {
+			e.pull()
+		}
+		return 0
+	}
+
+	elapsed := now.Sub(e.lastQuote)
+	if e.quoting && elapsed < e.config.MinInterval {
+		return e.config.MinInterval - elapsed
+	}
+
+	interval := e.Interval(snapshot.Volatility)
+	moved := math.Abs(snapshot.FairValue-e.lastQuoteFair) >= e.config.PriceMoveTicks*e.config.TickSize
+	if e.quoting && !moved && elapsed < interval {
+		return interval - elapsed
+	}
+
+	e.quoter.Quote(snapshot)
+	e.quoting = true
+	e.lastQuote = now
+	e.lastQuoteFair = snapshot.FairValue
+	return interval
+}
+
+// pull cancels resting quotes
+func (e *Engine) pull() {
+	e.quoter.Pull()
+	e.quoting = false
+}
+
+// Interval returns the re-quote interval for the given annualized volatility
+func (e *Engine) Interval(volatility float64) time.Duration {
+	low, high := e.config.LowVolatility, e.config.HighVolatility
+	if high == 0 {
+		return e.config.MaxInterval
+	}
+
+	if volatility <= low {
+		return e.config.MaxInterval
+	}
+	if volatility >= high {
+		return e.config.MinInterval
+	}
+
+	// Linear scaling between the thresholds
+	scale := (volatility - low) / (high - low)
+	span := e.config.MaxInterval - e.config.MinInterval
+	return e.config.MaxInterval - time.Duration(scale*float64(span))
+}
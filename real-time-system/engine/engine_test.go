@@ -0,0 +1,309 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/clock"
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+	"github.com/369geofreeman/inventory-control/real-time-system/orderbook"
+	"github.com/369geofreeman/inventory-control/real-time-system/volatility"
+)
+
+// recordingQuoter remembers the mid-price of every quote
+type recordingQuoter struct {
+	mu     sync.Mutex
+	quotes []float64
+	pulls  int
+}
+
+func (q *recordingQuoter) Quote(snapshot marketdata.MarketSnapshot) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.quotes = append(q.quotes, snapshot.MidPrice)
+}
+
+func (q *recordingQuoter) Pull() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pulls++
+}
+
+func (q *recordingQuoter) counts() (int, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.quotes), q.pulls
+}
+
+// readyFeed returns a feed with enough data to quote around mid
+func readyFeed(mid float64) *marketdata.Feed {
+	feed := marketdata.NewFeed("test", "BTCUSDT")
+	feed.SetVolatilityEstimator(volatility.NewRealized(time.Millisecond, 2))
+	for i := 0; i < 50; i++ {
+		feed.OnTrade(marketdata.Trade{Price: mid})
+	}
+	feed.OnTicker(marketdata.Ticker{LastPrice: mid})
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		feed.OnBookUpdate(marketdata.BookUpdate{
+			Type:         marketdata.Snapshot,
+			Bids:         []orderbook.Level{{Price: mid - 0.5, Size: 1}},
+			Asks:         []orderbook.Level{{Price: mid + 0.5, Size: 1}},
+			ExchangeTime: start.Add(time.Duration(i) * time.Millisecond),
+		})
+	}
+	return feed
+}
+
+func setMid(feed *marketdata.Feed, mid float64) {
+	feed.OnBookUpdate(marketdata.BookUpdate{
+		Type: marketdata.Snapshot,
+		Bids: []orderbook.Level{{Price: mid - 0.5, Size: 1}},
+		Asks: []orderbook.Level{{Price: mid + 0.5, Size: 1}},
+	})
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"missing tick size", Config{}},
+		{"inverted intervals", Config{TickSize: 0.1, MinInterval: time.Second, MaxInterval: time.Millisecond}},
+		{"inverted volatility thresholds", Config{TickSize: 0.1, LowVolatility: 1, HighVolatility: 0.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(marketdata.NewFeed("test", "BTCUSDT"), &recordingQuoter{}, tt.config); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestEvaluateThrottles(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	quoter := &recordingQuoter{}
+	engine, err := New(marketdata.NewFeed("test", "BTCUSDT"), quoter, Config{
+		TickSize:       0.1,
+		MinInterval:    100 * time.Millisecond,
+		MaxInterval:    10 * time.Second,
+		PriceMoveTicks: 5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ready := marketdata.MarketSnapshot{IsOrderBookReady: true, IsVolatilityReady: true, IsTradeReady: true, IsTickerReady: true}
+	at := func(fair float64) marketdata.MarketSnapshot {
+		s := ready
+		s.MidPrice = fair
+		s.FairValue = fair
+		return s
+	}
+
+	steps := []struct {
+		name     string
+		snapshot marketdata.MarketSnapshot
+		offset   time.Duration
+		quotes   int
+		pulls    int
+		wait     time.Duration
+	}{
+		{"not ready", marketdata.MarketSnapshot{}, 0, 0, 0, 0},
+		{"first quote", at(100), 0, 1, 0, 10 * time.Second},
+		{"large move within min interval", at(101), 50 * time.Millisecond, 1, 0, 50 * time.Millisecond},
+		{"large move after min interval", at(101), 100 * time.Millisecond, 2, 0, 10 * time.Second},
+		{"small move", at(101.4), time.Second, 2, 0, 9100 * time.Millisecond},
+		{"max interval elapsed", at(101.4), 10100 * time.Millisecond, 3, 0, 10 * time.Second},
+		{"data lost", marketdata.MarketSnapshot{}, 10200 * time.Millisecond, 3, 1, 0},
+		{"data recovered", at(101.4), 10210 * time.Millisecond, 4, 1, 10 * time.Second},
+	}
+	for _, step := range steps {
+		wait := engine.evaluate(step.snapshot, start.Add(step.offset))
+		quotes, pulls := quoter.counts()
+		if quotes != step.quotes || pulls != step.pulls || wait != step.wait {
+			t.Errorf("%s: expected %d quotes, %d pulls and wait %v, got %d, %d and %v",
+				step.name, step.quotes, step.pulls, step.wait, quotes, pulls, wait)
+		}
+	}
+}
+
+func TestDisabledThrottles(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	quoter := &recordingQuoter{}
+	engine, err := New(marketdata.NewFeed("test", "BTCUSDT"), quoter, Config{
+		TickSize:       0.1,
+		MinInterval:    -1,
+		MaxInterval:    10 * time.Second,
+		PriceMoveTicks: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if engine.config.MinInterval != 0 || engine.config.PriceMoveTicks != 0 {
+		t.Fatalf("Expected both throttles disabled, got %+v", engine.config)
+	}
+
+	// Every update is quoted, however soon and however small the move
+	s := marketdata.MarketSnapshot{IsOrderBookReady: true, IsVolatilityReady: true, IsTradeReady: true, IsTickerReady: true}
+	for i := 0; i < 3; i++ {
+		s.FairValue = 100 + float64(i)*0.01
+		engine.evaluate(s, start)
+	}
+	if quotes, _ := quoter.counts(); quotes != 3 {
+		t.Errorf("Expected 3 quotes, got %d", quotes)
+	}
+}
+
+func TestStep(t *testing.T) {
+	feed := readyFeed(100)
+	quoter := &recordingQuoter{}
+	engine, err := New(feed, quoter, Config{TickSize: 0.1, MaxInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Steps evaluate the feed synchronously without Run
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if wait := engine.Step(start); wait != time.Hour {
+		t.Errorf("Expected the next quote due in an hour, got %v", wait)
+	}
+	feed.Reset()
+	if wait := engine.Step(start.Add(time.Second)); wait != 0 {
+		t.Errorf("Expected nothing due once the feed is reset, got %v", wait)
+	}
+	if quotes, pulls := quoter.counts(); quotes != 1 || pulls != 1 {
+		t.Errorf("Expected 1 quote and 1 pull, got %d and %d", quotes, pulls)
+	}
+}
+
+func TestRunThrottlesOnClock(t *testing.T) {
+	feed := readyFeed(100)
+	quoter := &recordingQuoter{}
+	sim := clock.NewSimulated(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	engine, err := New(feed, quoter, Config{
+		TickSize:    0.1,
+		MinInterval: time.Hour,
+		MaxInterval: 2 * time.Hour,
+		Clock:       sim,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go engine.Run(ctx)
+
+	waitForQuotes := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if quotes, _ := quoter.counts(); quotes >= n {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %d quotes", n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitForQuotes(1)
+
+	// The move is held back for an hour of simulated time, which passes as
+	// soon as the clock is advanced
+	setMid(feed, 101)
+	time.Sleep(50 * time.Millisecond)
+	if quotes, _ := quoter.counts(); quotes != 1 {
+		t.Fatalf("Expected the move to wait for the simulated clock, got %d quotes", quotes)
+	}
+	sim.Advance(time.Hour)
+	waitForQuotes(2)
+}
+
+func TestIntervalScalesWithVolatility(t *testing.T) {
+	engine, err := New(marketdata.NewFeed("test", "BTCUSDT"), &recordingQuoter{}, Config{
+		TickSize:       0.5,
+		MinInterval:    10 * time.Millisecond,
+		MaxInterval:    1010 * time.Millisecond,
+		LowVolatility:  0.25,
+		HighVolatility: 1.25,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		volatility float64
+		expected   time.Duration
+	}{
+		{0, 1010 * time.Millisecond},
+		{0.25, 1010 * time.Millisecond},
+		{0.75, 510 * time.Millisecond},
+		{1.25, 10 * time.Millisecond},
+		{5, 10 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := engine.Interval(tt.volatility); got != tt.expected {
+			t.Errorf("Interval(%v): expected %v, got %v", tt.volatility, tt.expected, got)
+		}
+	}
+}
+
+func TestRunQuotesOnUpdatesAndPullsOnCancel(t *testing.T) {
+	feed := readyFeed(100)
+	quoter := &recordingQuoter{}
+	engine, err := New(feed, quoter, Config{
+		TickSize:    0.1,
+		MinInterval: time.Millisecond,
+		MaxInterval: time.Hour,
+		Clock:       clock.Real{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- engine.Run(ctx) }()
+
+	waitForQuotes := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if quotes, _ := quoter.counts(); quotes >= n {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %d quotes", n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitForQuotes(1)
+
+	// A move within the min interval is quoted once the throttle expires
+	setMid(feed, 101)
+	waitForQuotes(2)
+
+	cancel()
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for Run to return")
+	}
+	if _, pulls := quoter.counts(); pulls != 1 {
+		t.Errorf("Expected quotes to be pulled on shutdown, got %d pulls", pulls)
+	}
+	quoter.mu.Lock()
+	defer quoter.mu.Unlock()
+	if last := quoter.quotes[len(quoter.quotes)-1]; last != 101 {
+		t.Errorf("Expected the last quote around 101, got %f", last)
+	}
+}
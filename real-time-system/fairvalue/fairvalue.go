@@ -0,0 +1,248 @@
+package fairvalue
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/orderbook"
+	"github.com/369geofreeman/inventory-control/real-time-system/ring"
+)
+
+// Reference selects the price quotes are centered on
+type Reference string
+
+const (
+	Mid         Reference = "mid"          // Halfway between the best bid and ask
+	WeightedMid Reference = "weighted-mid" // Best bid and ask weighted by the opposite size
+	Microprice  Reference = "microprice"   // Mid adjusted by the expected next mid move
+)
+
+// decay is the weight kept by microprice observations at every mid change,
+// so the calibration follows the last few thousand changes
+const decay = 0.999
+
+// Config configures the fair value model
+type Config struct {
+	Reference       Reference // Price published as the fair value, Mid by default
+	ImbalanceLevels int       // Levels per side the book imbalance is measured over, 5 by default
+	OFIWindow       int       // Book updates the order flow imbalance is summed over, 100 by default
+
+	// The microprice adjustment is estimated per bucket of top of book
+	// imbalance and only used once a bucket has MinObservations updates,
+	// until then the weighted mid is used
+	Buckets         int // 10 by default
+	MinObservations int // 50 by default
+}
+
+// withDefaults returns the config with zero values replaced by defaults
+func (c Config) withDefaults() Config {
+	if c.Reference == "" {
+		c.Reference = Mid
+	}
+	if c.ImbalanceLevels == 0 {
+		c.ImbalanceLevels = 5
+	}
+	if c.OFIWindow == 0 {
+		c.OFIWindow = 100
+	}
+	if c.Buckets == 0 {
+		c.Buckets = 10
+	}
+	if c.MinObservations == 0 {
+		c.MinObservations = 50
+	}
+	return c
+}
+
+// Model derives fair value signals from an order book after every update.
+// Model is not safe for concurrent use.
+type Model struct {
+	config Config
+
+	mid         float64
+	weightedMid float64
+	microprice  float64
+	imbalance   float64
+
+	// Order flow imbalance state
+	prevBid, prevAsk orderbook.Level
+	hasPrev          bool
+	flows            *ring.Buffer[float64]
+	flowSum          float64
+	flowEvictions    int
+
+	// Microprice calibration. Every update is an observation of the mid move
+	// that follows it, which is only known at the next mid change.
+	pending      []float64 // Updates per bucket since the last mid change
+	pendingMid   float64
+	adjustments  []float64 // Weighted sum of mid moves in spreads per bucket
+	observations []float64 // Weighted number of observations per bucket
+}
+
+// New creates a fair value model
+func New(config Config) (*Model, error) {
+	config = config.withDefaults()
+	switch config.Reference {
+	case Mid, WeightedMid, Microprice:
+	default:
+		return nil, fmt.Errorf("unknown reference price %q", config.Reference)
+	}
+	if config.ImbalanceLevels < 1 || config.OFIWindow < 1 || config.Buckets < 1 || config.MinObservations < 1 {
+		return nil, errors.New("levels, window, buckets and observations should be at least 1")
+	}
+
+	return &Model{
+		config:       config,
+		flows:        ring.NewBuffer[float64](config.OFIWindow),
+		pending:      make([]float64, config.Buckets),
+		adjustments:  make([]float64, config.Buckets),
+		observations: make([]float64, config.Buckets),
+	}, nil
+}
+
+// Update recalculates every signal from the book. Books with an empty side
+// are ignored.
+func (m *Model) Update(book *orderbook.Book) {
+	bid, okBid := book.BestBid()
+	ask, okAsk := book.BestAsk()
+	if !okBid || !okAsk || bid.Size+ask.Size <= 0 {
+		return
+	}
+
+	m.mid = (bid.Price + ask.Price) / 2
+	m.weightedMid = (bid.Price*ask.Size + ask.Price*bid.Size) / (bid.Size + ask.Size)
+
+	bidVolume := book.Volume(orderbook.Bid, m.config.ImbalanceLevels)
+	askVolume := book.Volume(orderbook.Ask, m.config.ImbalanceLevels)
+	m.imbalance = (bidVolume - askVolume) / (bidVolume + askVolume)
+
+	m.updateOrderFlow(bid, ask)
+	m.updateMicroprice(bid, ask)
+}
+
+// updateOrderFlow adds the order flow imbalance of the change in the top of
+// book (Cont, Kukanov and Stoikov): size added at or above the best bid and
+// removed at or below the best ask is buying pressure, and vice versa
+func (m *Model) updateOrderFlow(bid, ask orderbook.Level) {
+	if m.hasPrev {
+		var flow float64
+		if bid.Price >= m.prevBid.Price {
+			flow += bid.Size
+		}
+		if bid.Price <= m.prevBid.Price {
+			flow -= m.prevBid.Size
+		}
+		if ask.Price <= m.prevAsk.Price {
+			flow -= ask.Size
+		}
+		if ask.Price >= m.prevAsk.Price {
+			flow += m.prevAsk.Size
+		}
+
+		if evicted, ok := m.flows.Push(flow); ok {
+			m.flowSum -= evicted
+			m.flowEvictions++
+		}
+		m.flowSum += flow
+		if m.flowEvictions >= m.flows.Cap() {
+			// Rebuild the sum to bound rounding error
+			m.flowEvictions = 0
+			m.flowSum = 0
+			m.flows.Do(func(f float64) { m.flowSum += f })
+		}
+	}
+	m.prevBid, m.prevAsk = bid, ask
+	m.hasPrev = true
+}
+
+// updateMicroprice calibrates the expected next mid move per bucket of top
+// of book imbalance, a first order version of Stoikov's microprice that
+// measures moves in units of the spread rather than conditioning on it
+func (m *Model) updateMicroprice(bid, ask orderbook.Level) {
+	spread := ask.Price - bid.Price
+	if m.pendingMid != 0 && m.mid != m.pendingMid && spread > 0 {
+		move := (m.mid - m.pendingMid) / spread
+		for b, n := range m.pending {
+			m.adjustments[b] = decay*m.adjustments[b] + n*move
+			m.observations[b] = decay*m.observations[b] + n
+			m.pending[b] = 0
+		}
+	}
+	m.pendingMid = m.mid
+
+	b := m.bucket(bid, ask)
+	m.pending[b]++
+
+	m.microprice = m.weightedMid
+	if m.observations[b] >= float64(m.config.MinObservations) {
+		m.microprice = m.mid + spread*m.adjustments[b]/m.observations[b]
+	}
+}
+
+// bucket returns the imbalance bucket of the top of book
+func (m *Model) bucket(bid, ask orderbook.Level) int {
+	imbalance := bid.Size / (bid.Size + ask.Size)
+	b := int(imbalance * float64(m.config.Buckets))
+	if b >= m.config.Buckets {
+		b = m.config.Buckets - 1
+	}
+	return b
+}
+
+// Reset discards the order flow and the updates awaiting a mid change after
+// a gap in the book. The microprice calibration is kept as it describes the
+// symbol rather than the session.
+func (m *Model) Reset() {
+	m.mid = 0
+	m.weightedMid = 0
+	m.microprice = 0
+	m.imbalance = 0
+	m.hasPrev = false
+	m.flows.Reset()
+	m.flowSum = 0
+	m.flowEvictions = 0
+	m.pendingMid = 0
+	for b := range m.pending {
+		m.pending[b] = 0
+	}
+}
+
+// Mid returns the mid-price
+func (m *Model) Mid() float64 {
+	return m.mid
+}
+
+// WeightedMid returns the best bid and ask weighted by the size on the
+// opposite side, which leans towards the side more likely to be depleted
+func (m *Model) WeightedMid() float64 {
+	return m.weightedMid
+}
+
+// Microprice returns the mid adjusted by the expected next mid move given
+// the top of book imbalance, the weighted mid until calibrated
+func (m *Model) Microprice() float64 {
+	return m.microprice
+}
+
+// Imbalance returns (bid - ask) / (bid + ask) size over the configured
+// number of levels, between -1 and 1
+func (m *Model) Imbalance() float64 {
+	return m.imbalance
+}
+
+// OrderFlowImbalance returns the order flow imbalance summed over the window
+// of book updates, positive under buying pressure
+func (m *Model) OrderFlowImbalance() float64 {
+	return m.flowSum
+}
+
+// Reference returns the price selected by the config
+func (m *Model) Reference() float64 {
+	switch m.config.Reference {
+	case WeightedMid:
+		return m.weightedMid
+	case Microprice:
+		return m.microprice
+	}
+	return m.mid
+}
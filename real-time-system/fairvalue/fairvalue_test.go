@@ -0,0 +1,120 @@
+package fairvalue
+
+import (
+	"math"
+	"testing"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/orderbook"
+)
+
+func book(bids, asks []orderbook.Level) *orderbook.Book {
+	b := orderbook.New()
+	b.ApplySnapshot(bids, asks)
+	return b
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	if _, err := New(Config{Reference: "last"}); err == nil {
+		t.Error("Expected an error for an unknown reference")
+	}
+	if _, err := New(Config{OFIWindow: -1}); err == nil {
+		t.Error("Expected an error for a negative window")
+	}
+}
+
+func TestWeightedMidAndImbalance(t *testing.T) {
+	m, err := New(Config{ImbalanceLevels: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Update(book(
+		[]orderbook.Level{{Price: 100, Size: 3}, {Price: 99, Size: 5}, {Price: 98, Size: 100}},
+		[]orderbook.Level{{Price: 101, Size: 1}, {Price: 102, Size: 1}},
+	))
+
+	if m.Mid() != 100.5 {
+		t.Errorf("Expected mid 100.5, got %f", m.Mid())
+	}
+	// Three times more size on the bid pulls the fair value towards the ask
+	if m.WeightedMid() != 100.75 {
+		t.Errorf("Expected weighted mid 100.75, got %f", m.WeightedMid())
+	}
+	// The third bid level is outside the two levels measured
+	if m.Imbalance() != 0.6 {
+		t.Errorf("Expected imbalance 0.6, got %f", m.Imbalance())
+	}
+	// Uncalibrated microprice falls back to the weighted mid
+	if m.Microprice() != m.WeightedMid() {
+		t.Errorf("Expected microprice %f before calibration, got %f", m.WeightedMid(), m.Microprice())
+	}
+	if m.Reference() != m.Mid() {
+		t.Errorf("Expected the mid as the default reference, got %f", m.Reference())
+	}
+
+	// Books with an empty side are ignored
+	m.Update(book([]orderbook.Level{{Price: 50, Size: 1}}, nil))
+	if m.Mid() != 100.5 {
+		t.Errorf("Expected a one-sided book to be ignored, got mid %f", m.Mid())
+	}
+}
+
+func TestOrderFlowImbalance(t *testing.T) {
+	m, err := New(Config{OFIWindow: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		name     string
+		bid, ask orderbook.Level
+		expected float64
+	}{
+		{"first update", orderbook.Level{Price: 100, Size: 2}, orderbook.Level{Price: 101, Size: 2}, 0},
+		// Bid size added at the same price is +3
+		{"bid size added", orderbook.Level{Price: 100, Size: 5}, orderbook.Level{Price: 101, Size: 2}, 3},
+		// The ask improving to 100.5 removes the old ask from view and adds the new one, -1
+		{"ask improved", orderbook.Level{Price: 100, Size: 5}, orderbook.Level{Price: 100.5, Size: 1}, 2},
+		// The bid dropping removes all 5 bid size and the +3 leaves the window
+		{"bid dropped", orderbook.Level{Price: 99.5, Size: 4}, orderbook.Level{Price: 100.5, Size: 1}, -6},
+	}
+	for _, step := range steps {
+		m.Update(book([]orderbook.Level{step.bid}, []orderbook.Level{step.ask}))
+		if got := m.OrderFlowImbalance(); got != step.expected {
+			t.Errorf("%s: expected order flow imbalance %f, got %f", step.name, step.expected, got)
+		}
+	}
+}
+
+func TestMicropriceLearnsFromImbalance(t *testing.T) {
+	m, err := New(Config{Reference: Microprice, Buckets: 2, MinObservations: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A heavy bid is followed by the mid ticking up half a spread, a heavy
+	// ask by the mid ticking down, so the microprice leads the weighted mid
+	mid := 100.0
+	for i := 0; i < 50; i++ {
+		m.Update(book([]orderbook.Level{{Price: mid - 0.5, Size: 9}}, []orderbook.Level{{Price: mid + 0.5, Size: 1}}))
+		mid += 0.5
+		m.Update(book([]orderbook.Level{{Price: mid - 0.5, Size: 1}}, []orderbook.Level{{Price: mid + 0.5, Size: 9}}))
+		mid -= 0.5
+	}
+
+	m.Update(book([]orderbook.Level{{Price: mid - 0.5, Size: 9}}, []orderbook.Level{{Price: mid + 0.5, Size: 1}}))
+	if math.Abs(m.Microprice()-(mid+0.5)) > 1e-9 {
+		t.Errorf("Expected a heavy bid to imply a microprice of %f, got %f", mid+0.5, m.Microprice())
+	}
+	if m.Reference() != m.Microprice() {
+		t.Errorf("Expected the microprice as the reference, got %f", m.Reference())
+	}
+
+	// The calibration survives a reset of the session state
+	m.Reset()
+	m.Update(book([]orderbook.Level{{Price: mid, Size: 1}}, []orderbook.Level{{Price: mid + 1, Size: 9}}))
+	if math.Abs(m.Microprice()-mid) > 1e-9 {
+		t.Errorf("Expected a heavy ask to imply a microprice of %f after reset, got %f", mid, m.Microprice())
+	}
+	if m.OrderFlowImbalance() != 0 {
+		t.Errorf("Expected no order flow after reset, got %f", m.OrderFlowImbalance())
+	}
+}
@@ -0,0 +1,243 @@
+package intensity
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/ring"
+)
+
+// Config controls the window and distance buckets the intensity is fitted over
+type Config struct {
+	Step    float64 // Width of a distance bucket in price, e.g. the tick size, required
+	Buckets int     // Number of distance buckets from the mid-price fitted, 20 by default
+
+	Window    time.Duration // Rolling window trades are counted over, 10 minutes by default
+	MaxTrades int           // Most trades kept in the window, 100000 by default
+
+	MinTrades  int // Trades in the window required before publishing a fit, 100 by default
+	MinBuckets int // Buckets reached by a trade required for a fit, 3 by default
+}
+
+// withDefaults returns the config with zero values replaced by defaults
+func (c Config) withDefaults() Config {
+	if c.Buckets == 0 {
+		c.Buckets = 20
+	}
+	if c.Window == 0 {
+		c.Window = 10 * time.Minute
+	}
+	if c.MaxTrades == 0 {
+		c.MaxTrades = 100000
+	}
+	if c.MinTrades == 0 {
+		c.MinTrades = 100
+	}
+	if c.MinBuckets == 0 {
+		c.MinBuckets = 3
+	}
+	return c
+}
+
+// validate checks the config after defaults have been applied
+func (c Config) validate() error {
+	if c.Step <= 0 {
+		return errors.New("step should be greater than 0")
+	}
+	if c.Window <= 0 {
+		return errors.New("window should be greater than 0")
+	}
+	if c.MinBuckets < 2 || c.Buckets < c.MinBuckets {
+		return errors.New("buckets should satisfy 2 <= min buckets <= buckets")
+	}
+	if c.MaxTrades < 1 || c.MinTrades < 1 || c.MinTrades > c.MaxTrades {
+		return errors.New("trades should satisfy 1 <= min trades <= max trades")
+	}
+	return nil
+}
+
+// Side is the side of the order book a trade reached
+type Side int
+
+const (
+	Bid Side = iota // Reached by sell aggressors
+	Ask             // Reached by buy aggressors
+)
+
+// Estimate is a fit of the fill intensity λ(δ) = A·exp(-kδ) of one side
+type Estimate struct {
+	A float64 // Trades per second reaching the mid-price on one side
+	K float64 // Decay of the intensity per unit of price
+
+	R2      float64   // Coefficient of determination of the log-linear fit
+	Trades  int       // Trades in the window
+	Buckets int       // Buckets reached by a trade the fit used
+	At      time.Time // Time of the last trade in the fit
+}
+
+// sample is the distance of a trade from the mid-price
+type sample struct {
+	at     time.Time
+	side   Side
+	bucket int
+}
+
+// Calibrator estimates how often a quote at a distance δ from the mid-price
+// would be filled, from how often trades reached that distance over a rolling
+// window. Every Step up to Buckets·Step the intensity is the number of trades
+// at least that far from the mid-price per second, and ln λ is regressed on δ
+// to fit A and k.
+//
+// A quote is only filled by aggressors from the other side, so distances are
+// measured from the mid-price to the side a trade reached and the intensity
+// is averaged over both sides. Counting both sides together would double A
+// for a quote on either of them. A Calibrator is not safe for concurrent use.
+type Calibrator struct {
+	config  Config
+	samples *ring.Buffer[sample]
+	counts  [2][]int  // Trades in the window per side and bucket, the last one counts every trade beyond
+	start   time.Time // Time of the oldest trade counted since a reset
+
+	estimate Estimate
+	ready    bool
+}
+
+// New creates a calibrator with the given config
+func New(config Config) (*Calibrator, error) {
+	config = config.withDefaults()
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &Calibrator{
+		config:  config,
+		samples: ring.NewBuffer[sample](config.MaxTrades),
+		counts:  [2][]int{make([]int, config.Buckets+1), make([]int, config.Buckets+1)},
+	}, nil
+}
+
+// Add counts a trade at price that reached the given side of the book when
+// the mid-price was mid, and refits. A trade through the mid-price counts as
+// reaching it.
+func (c *Calibrator) Add(at time.Time, side Side, price, mid float64) {
+	if price <= 0 || mid <= 0 || (side != Bid && side != Ask) {
+		return
+	}
+	if c.start.IsZero() {
+		c.start = at
+	}
+
+	distance := price - mid
+	if side == Bid {
+		distance = mid - price
+	}
+	bucket := int(math.Max(distance, 0) / c.config.Step)
+	if bucket > c.config.Buckets {
+		bucket = c.config.Buckets
+	}
+	if evicted, ok := c.samples.Push(sample{at: at, side: side, bucket: bucket}); ok {
+		c.counts[evicted.side][evicted.bucket]--
+		// The trades held no longer span back to the first one
+		if oldest, ok := c.samples.Front(); ok {
+			c.start = oldest.at
+		}
+	}
+	c.counts[side][bucket]++
+
+	// Forget trades that left the window
+	for {
+		oldest, ok := c.samples.Front()
+		if !ok || at.Sub(oldest.at) <= c.config.Window {
+			break
+		}
+		c.samples.PopFront()
+		c.counts[oldest.side][oldest.bucket]--
+	}
+	c.fit(at)
+}
+
+// fit regresses the log of the intensity averaged over both sides of every
+// bucket reached by a trade on its distance, publishing the fit if it has
+// enough data and a decaying intensity
+func (c *Calibrator) fit(now time.Time) {
+	n := c.samples.Len()
+	if n < c.config.MinTrades {
+		return
+	}
+	// Until the window fills the trades span less than it
+	elapsed := now.Sub(c.start)
+	if elapsed > c.config.Window {
+		elapsed = c.config.Window
+	}
+	if elapsed <= 0 {
+		return
+	}
+
+	var points int
+	var sumX, sumY, sumXX, sumXY, sumYY float64
+	reached := n
+	for i := 0; i < c.config.Buckets; i++ {
+		if reached == 0 {
+			break
+		}
+		x := float64(i) * c.config.Step
+		y := math.Log(float64(reached) / 2 / elapsed.Seconds())
+		points++
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+		sumYY += y * y
+		reached -= c.counts[Bid][i] + c.counts[Ask][i]
+	}
+	if points < c.config.MinBuckets {
+		return
+	}
+
+	p := float64(points)
+	varX := sumXX - sumX*sumX/p
+	varY := sumYY - sumY*sumY/p
+	covXY := sumXY - sumX*sumY/p
+	slope := covXY / varX
+	if slope >= 0 {
+		// Trades further away should never be more frequent
+		return
+	}
+	r2 := 1.0
+	if varY > 0 {
+		r2 = covXY * covXY / (varX * varY)
+	}
+
+	c.estimate = Estimate{
+		A:       math.Exp((sumY - slope*sumX) / p),
+		K:       -slope,
+		R2:      r2,
+		Trades:  n,
+		Buckets: points,
+		At:      now,
+	}
+	c.ready = true
+}
+
+// Estimate returns the latest fit
+func (c *Calibrator) Estimate() Estimate {
+	return c.estimate
+}
+
+// Ready reports whether a fit has been published since the last reset
+func (c *Calibrator) Ready() bool {
+	return c.ready
+}
+
+// Reset discards every trade and the fit
+func (c *Calibrator) Reset() {
+	c.samples.Reset()
+	for _, counts := range c.counts {
+		for i := range counts {
+			counts[i] = 0
+		}
+	}
+	c.start = time.Time{}
+	c.estimate = Estimate{}
+	c.ready = false
+}
@@ -0,0 +1,180 @@
+package intensity
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// addTrades adds trades reaching each distance from a mid-price of 100 on
+// both sides as often as λ(δ) = a·exp(-kδ) predicts, evenly spread over the
+// period
+func addTrades(c *Calibrator, start time.Time, period time.Duration, a, k float64, buckets int) {
+	var distances []float64
+	reaching := func(i int) int {
+		return int(math.Round(a * period.Seconds() * math.Exp(-k*float64(i))))
+	}
+	for i := 0; i < buckets; i++ {
+		for n := reaching(i) - reaching(i+1); n > 0; n-- {
+			distances = append(distances, float64(i)+0.5)
+		}
+	}
+	for n := reaching(buckets); n > 0; n-- {
+		// The tail beyond the last bucket
+		distances = append(distances, float64(buckets)+0.5)
+	}
+
+	// Interleave trades reaching the bids and the asks over the period
+	spacing := period / time.Duration(2*len(distances))
+	for j, d := range distances {
+		c.Add(start.Add(time.Duration(2*j)*spacing), Ask, 100+d, 100)
+		c.Add(start.Add(time.Duration(2*j+1)*spacing), Bid, 100-d, 100)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	invalid := []Config{
+		{},
+		{Step: 1, Buckets: 2, MinBuckets: 3},
+		{Step: 1, MinBuckets: 1},
+		{Step: 1, MaxTrades: 10, MinTrades: 20},
+		{Step: 1, Window: -time.Second},
+	}
+	for i, config := range invalid {
+		if _, err := New(config); err == nil {
+			t.Errorf("Expected config %d to be rejected", i)
+		}
+	}
+}
+
+func TestCalibrate(t *testing.T) {
+	c, err := New(Config{Step: 1, Buckets: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1700000000, 0)
+	addTrades(c, start, 10*time.Minute, 2, 0.5, 10)
+
+	if !c.Ready() {
+		t.Fatal("Expected a fit after a full window of trades")
+	}
+	estimate := c.Estimate()
+	if math.Abs(estimate.A-2)/2 > 0.03 {
+		t.Errorf("Expected A close to 2, got %f", estimate.A)
+	}
+	if math.Abs(estimate.K-0.5)/0.5 > 0.03 {
+		t.Errorf("Expected k close to 0.5, got %f", estimate.K)
+	}
+	if estimate.R2 < 0.99 {
+		t.Errorf("Expected an R² close to 1, got %f", estimate.R2)
+	}
+	if estimate.Buckets != 10 {
+		t.Errorf("Expected the fit to use 10 buckets, got %d", estimate.Buckets)
+	}
+
+	// A steeper decay in the next window replaces the old trades
+	addTrades(c, start.Add(time.Hour), 10*time.Minute, 2, 1, 10)
+	if k := c.Estimate().K; math.Abs(k-1) > 0.05 {
+		t.Errorf("Expected k close to 1 after the window rolled, got %f", k)
+	}
+	if trades := c.Estimate().Trades; trades > 2400 {
+		t.Errorf("Expected only the trades of the last window, got %d", trades)
+	}
+
+	c.Reset()
+	if c.Ready() || c.Estimate() != (Estimate{}) {
+		t.Error("Expected no fit after a reset")
+	}
+}
+
+func TestMinimumData(t *testing.T) {
+	c, err := New(Config{Step: 1, MinTrades: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1700000000, 0)
+
+	// Too few trades
+	for i := 0; i < 49; i++ {
+		c.Add(start.Add(time.Duration(i)*time.Second), Ask, 100+float64(i%3), 100)
+	}
+	if c.Ready() {
+		t.Error("Expected no fit before the minimum number of trades")
+	}
+
+	// Enough trades, but every one at the mid-price leaves a single bucket
+	c.Reset()
+	for i := 0; i < 100; i++ {
+		c.Add(start.Add(time.Duration(i)*time.Second), Bid, 100, 100)
+	}
+	if c.Ready() {
+		t.Error("Expected no fit with a single bucket")
+	}
+
+	// Invalid prices are ignored
+	c.Add(start, Ask, 0, 100)
+	c.Add(start, Bid, 100, 0)
+	if c.samples.Len() != 100 {
+		t.Errorf("Expected 100 trades, got %d", c.samples.Len())
+	}
+}
+
+func TestSides(t *testing.T) {
+	c, err := New(Config{Step: 1, Buckets: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1700000000, 0)
+	addTrades(c, start, 10*time.Minute, 2, 0.5, 10)
+
+	// Trades reaching each side as often give the intensity of one side,
+	// not twice it
+	if a := c.Estimate().A; math.Abs(a-2)/2 > 0.03 {
+		t.Errorf("Expected A close to 2 per side, got %f", a)
+	}
+
+	// A sell aggressor above the mid-price reached the bids at the mid-price
+	c.Reset()
+	c.Add(start, Bid, 103, 100)
+	c.Add(start, Ask, 97, 100)
+	if c.counts[Bid][0] != 1 || c.counts[Ask][0] != 1 {
+		t.Errorf("Expected trades through the mid-price at distance 0, got %v", c.counts)
+	}
+	c.Add(start, Bid, 97, 100)
+	if c.counts[Bid][3] != 1 {
+		t.Errorf("Expected a sell 3 below the mid-price in bucket 3, got %v", c.counts[Bid])
+	}
+}
+
+func TestMaxTradesEviction(t *testing.T) {
+	c, err := New(Config{Step: 1, Buckets: 10, MaxTrades: 400})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1700000000, 0)
+
+	// Every 0.8s each side is reached 4 times at the mid-price, twice a tick
+	// away and once two ticks away, so λ(δ) = 5·exp(-δ·ln 2)
+	distances := []float64{0.5, 0.5, 1.5, 2.5}
+	for j := 0; j < 1200; j++ {
+		d := distances[j/2%len(distances)]
+		side, price := Ask, 100+d
+		if j%2 == 1 {
+			side, price = Bid, 100-d
+		}
+		c.Add(start.Add(time.Duration(j)*100*time.Millisecond), side, price, 100)
+	}
+
+	// The 400 trades kept span the last 40 seconds, not the 2 minutes since
+	// the first trade
+	estimate := c.Estimate()
+	if estimate.Trades != 400 {
+		t.Fatalf("Expected 400 trades, got %d", estimate.Trades)
+	}
+	if math.Abs(estimate.A-5)/5 > 0.03 {
+		t.Errorf("Expected A close to 5 after evicting the oldest trades, got %f", estimate.A)
+	}
+	if math.Abs(estimate.K-math.Ln2)/math.Ln2 > 0.03 {
+		t.Errorf("Expected k close to ln 2, got %f", estimate.K)
+	}
+}
@@ -0,0 +1,251 @@
+package liquidity
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/orderbook"
+)
+
+// Measure selects how the size of a band of the book is expressed
+type Measure string
+
+const (
+	Levels   Measure = "levels"   // Number of price levels
+	Base     Measure = "base"     // Quantity in the base asset
+	Notional Measure = "notional" // Quantity in the quote asset, price times size
+)
+
+// Config selects the bands and notionals measured on every book update
+type Config struct {
+	// Band around the mid-price, in basis points, and measure published as
+	// liquidity. 100bps in base quantity by default.
+	LiquidityBps     float64
+	LiquidityMeasure Measure
+
+	// Band and measure published as order book depth. 500bps in levels by default.
+	DepthBps     float64
+	DepthMeasure Measure
+
+	// Additional bands in basis points reported in Metrics
+	Bands []float64
+
+	// Quote notionals to report the cost of trading in Metrics, e.g. 10000
+	// for the slippage of buying or selling 10k USDT at market
+	CostNotionals []float64
+}
+
+// withDefaults returns the config with zero values replaced by defaults
+func (c Config) withDefaults() Config {
+	if c.LiquidityBps == 0 {
+		c.LiquidityBps = 100
+	}
+	if c.LiquidityMeasure == "" {
+		c.LiquidityMeasure = Base
+	}
+	if c.DepthBps == 0 {
+		c.DepthBps = 500
+	}
+	if c.DepthMeasure == "" {
+		c.DepthMeasure = Levels
+	}
+	return c
+}
+
+// validate checks the config after defaults have been applied
+func (c Config) validate() error {
+	for _, m := range []Measure{c.LiquidityMeasure, c.DepthMeasure} {
+		switch m {
+		case Levels, Base, Notional:
+		default:
+			return fmt.Errorf("unknown measure %q", m)
+		}
+	}
+	if c.LiquidityBps < 0 || c.DepthBps < 0 {
+		return errors.New("bands should not be negative")
+	}
+	for _, bps := range c.Bands {
+		if bps < 0 {
+			return errors.New("bands should not be negative")
+		}
+	}
+	for _, notional := range c.CostNotionals {
+		if notional <= 0 {
+			return errors.New("cost notionals should be greater than 0")
+		}
+	}
+	return nil
+}
+
+// Side is the size of one side of the book within a band
+type Side struct {
+	Levels   int
+	Base     float64
+	Notional float64
+}
+
+// value returns the size in the given measure
+func (s Side) value(m Measure) float64 {
+	switch m {
+	case Levels:
+		return float64(s.Levels)
+	case Notional:
+		return s.Notional
+	}
+	return s.Base
+}
+
+// Band is the size of both sides of the book within Bps of the mid-price
+type Band struct {
+	Bps      float64
+	Bid, Ask Side
+}
+
+// Cost is the slippage from the mid-price, in basis points, of buying and
+// selling Notional at market. It is +Inf if the book is not deep enough.
+type Cost struct {
+	Notional float64
+	BuyBps   float64
+	SellBps  float64
+}
+
+// Metrics are the liquidity measurements of a single book update
+type Metrics struct {
+	Bands []Band
+	Costs []Cost
+}
+
+// Band returns the measurements for the band of the given width
+func (m Metrics) Band(bps float64) (Band, bool) {
+	for _, band := range m.Bands {
+		if band.Bps == bps {
+			return band, true
+		}
+	}
+	return Band{}, false
+}
+
+// Clone returns a deep copy of the metrics
+func (m Metrics) Clone() Metrics {
+	return Metrics{
+		Bands: append([]Band(nil), m.Bands...),
+		Costs: append([]Cost(nil), m.Costs...),
+	}
+}
+
+// Calculator measures the liquidity of a book around its mid-price.
+// Calculator reuses its buffers and is not safe for concurrent use.
+type Calculator struct {
+	config  Config
+	metrics Metrics
+
+	liquidity, depth int // Indexes of the configured bands in metrics.Bands
+}
+
+// New creates a calculator for the given config
+func New(config Config) (*Calculator, error) {
+	config = config.withDefaults()
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	c := &Calculator{config: config}
+	c.liquidity = c.addBand(config.LiquidityBps)
+	c.depth = c.addBand(config.DepthBps)
+	for _, bps := range config.Bands {
+		c.addBand(bps)
+	}
+	for _, notional := range config.CostNotionals {
+		c.metrics.Costs = append(c.metrics.Costs, Cost{Notional: notional})
+	}
+	return c, nil
+}
+
+// addBand adds a band unless it is already measured and returns its index
+func (c *Calculator) addBand(bps float64) int {
+	for i, band := range c.metrics.Bands {
+		if band.Bps == bps {
+			return i
+		}
+	}
+	c.metrics.Bands = append(c.metrics.Bands, Band{Bps: bps})
+	return len(c.metrics.Bands) - 1
+}
+
+// Update measures the book around the given mid-price
+func (c *Calculator) Update(book *orderbook.Book, mid float64) {
+	for i := range c.metrics.Bands {
+		band := &c.metrics.Bands[i]
+		band.Bid = measure(book, orderbook.Bid, mid*(1-band.Bps/10000))
+		band.Ask = measure(book, orderbook.Ask, mid*(1+band.Bps/10000))
+	}
+	for i := range c.metrics.Costs {
+		cost := &c.metrics.Costs[i]
+		cost.BuyBps = slippage(book, orderbook.Ask, mid, cost.Notional)
+		cost.SellBps = slippage(book, orderbook.Bid, mid, cost.Notional)
+	}
+}
+
+// Reset clears every measurement
+func (c *Calculator) Reset() {
+	for i := range c.metrics.Bands {
+		c.metrics.Bands[i].Bid = Side{}
+		c.metrics.Bands[i].Ask = Side{}
+	}
+	for i := range c.metrics.Costs {
+		c.metrics.Costs[i].BuyBps = 0
+		c.metrics.Costs[i].SellBps = 0
+	}
+}
+
+// Liquidity returns the bid and ask liquidity in the configured band and measure
+func (c *Calculator) Liquidity() (bid, ask float64) {
+	band := c.metrics.Bands[c.liquidity]
+	return band.Bid.value(c.config.LiquidityMeasure), band.Ask.value(c.config.LiquidityMeasure)
+}
+
+// Depth returns the bid and ask depth in the configured band and measure
+func (c *Calculator) Depth() (bid, ask float64) {
+	band := c.metrics.Bands[c.depth]
+	return band.Bid.value(c.config.DepthMeasure), band.Ask.value(c.config.DepthMeasure)
+}
+
+// Metrics returns a copy of every measurement
+func (c *Calculator) Metrics() Metrics {
+	return c.metrics.Clone()
+}
+
+// measure sums one side of the book priced at or better than limit
+func measure(book *orderbook.Book, side orderbook.Side, limit float64) Side {
+	var s Side
+	s.Levels = book.LevelsWithin(side, limit)
+	for i := 0; i < s.Levels; i++ {
+		level := book.At(side, i)
+		s.Base += level.Size
+		s.Notional += level.Price * level.Size
+	}
+	return s
+}
+
+// slippage returns how far, in basis points, the average price of sweeping
+// notional from one side of the book is from the mid-price
+func slippage(book *orderbook.Book, side orderbook.Side, mid, notional float64) float64 {
+	remaining := notional
+	var base float64
+	for i := 0; i < book.Len(side) && remaining > 0; i++ {
+		level := book.At(side, i)
+		take := math.Min(remaining, level.Price*level.Size)
+		base += take / level.Price
+		remaining -= take
+	}
+	if remaining > notional*1e-12 || base == 0 {
+		return math.Inf(1)
+	}
+
+	average := notional / base
+	if side == orderbook.Ask {
+		return (average - mid) / mid * 10000
+	}
+	return (mid - average) / mid * 10000
+}
@@ -0,0 +1,117 @@
+package liquidity
+
+import (
+	"math"
+	"testing"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/orderbook"
+)
+
+func testBook() *orderbook.Book {
+	book := orderbook.New()
+	book.ApplySnapshot(
+		[]orderbook.Level{{Price: 99.9, Size: 1}, {Price: 99.5, Size: 2}, {Price: 98, Size: 5}},
+		[]orderbook.Level{{Price: 100.1, Size: 2}, {Price: 100.4, Size: 3}, {Price: 103, Size: 10}},
+	)
+	return book
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"unknown measure", Config{DepthMeasure: "orders"}},
+		{"negative band", Config{Bands: []float64{-10}}},
+		{"zero notional", Config{CostNotionals: []float64{0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.config); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestDefaultsMatchPreviousMetrics(t *testing.T) {
+	c, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Update(testBook(), 100)
+
+	// Quantity within 1%, i.e. between 99 and 101
+	if bid, ask := c.Liquidity(); bid != 3 || ask != 5 {
+		t.Errorf("Expected liquidity 3/5, got %f/%f", bid, ask)
+	}
+	// Levels within 5%
+	if bid, ask := c.Depth(); bid != 3 || ask != 3 {
+		t.Errorf("Expected depth 3/3, got %f/%f", bid, ask)
+	}
+}
+
+func TestBandsAndMeasures(t *testing.T) {
+	c, err := New(Config{
+		LiquidityBps:     50,
+		LiquidityMeasure: Notional,
+		DepthBps:         250,
+		DepthMeasure:     Base,
+		Bands:            []float64{10, 50},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Update(testBook(), 100)
+
+	if bid, ask := c.Liquidity(); math.Abs(bid-(99.9+199)) > 1e-9 || math.Abs(ask-(200.2+301.2)) > 1e-9 {
+		t.Errorf("Expected notional liquidity within 50bps of 298.9/501.4, got %f/%f", bid, ask)
+	}
+	if bid, ask := c.Depth(); bid != 8 || ask != 5 {
+		t.Errorf("Expected base depth within 250bps of 8/5, got %f/%f", bid, ask)
+	}
+
+	metrics := c.Metrics()
+	// The 50bps band is shared with liquidity rather than measured twice
+	if len(metrics.Bands) != 3 {
+		t.Fatalf("Expected 3 bands, got %d", len(metrics.Bands))
+	}
+	band, ok := metrics.Band(10)
+	if !ok || band.Bid != (Side{Levels: 1, Base: 1, Notional: 99.9}) || band.Ask.Levels != 1 || band.Ask.Base != 2 {
+		t.Errorf("Unexpected 10bps band %+v", band)
+	}
+	if _, ok := metrics.Band(20); ok {
+		t.Error("Expected no 20bps band")
+	}
+
+	// The copy is not changed by later updates
+	c.Reset()
+	if metrics.Bands[0].Bid.Levels == 0 {
+		t.Error("Expected Metrics to return a copy")
+	}
+}
+
+func TestCostToTrade(t *testing.T) {
+	c, err := New(Config{CostNotionals: []float64{100.1, 300.6, 10000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Update(testBook(), 100)
+	costs := c.Metrics().Costs
+
+	// 100.1 buys 1 at 100.1, selling it sweeps the 99.9 bid and part of the 99.5 bid
+	if math.Abs(costs[0].BuyBps-10) > 1e-9 {
+		t.Errorf("Expected buying 100.1 to cost 10bps, got %f", costs[0].BuyBps)
+	}
+	// 300.6 buys 2 at 100.1 and 1 at 100.4 for an average of 100.2
+	if math.Abs(costs[1].BuyBps-20) > 1e-9 {
+		t.Errorf("Expected buying 300.6 to cost 20bps, got %f", costs[1].BuyBps)
+	}
+	if costs[1].SellBps <= costs[0].SellBps || costs[0].SellBps <= 10 {
+		t.Errorf("Expected selling to cost more for larger notionals, got %f and %f", costs[0].SellBps, costs[1].SellBps)
+	}
+	// The book only holds about 1900 of asks
+	if !math.IsInf(costs[2].BuyBps, 1) || !math.IsInf(costs[2].SellBps, 1) {
+		t.Errorf("Expected an infinite cost beyond the book, got %f/%f", costs[2].BuyBps, costs[2].SellBps)
+	}
+}
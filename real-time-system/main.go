@@ -1,15 +1,71 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"math"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/369geofreeman/inventory-control/real-time-system/binanceconnector"
 	"github.com/369geofreeman/inventory-control/real-time-system/bybitconnector"
+	"github.com/369geofreeman/inventory-control/real-time-system/clock"
+	"github.com/369geofreeman/inventory-control/real-time-system/engine"
+	"github.com/369geofreeman/inventory-control/real-time-system/fairvalue"
+	"github.com/369geofreeman/inventory-control/real-time-system/intensity"
+	"github.com/369geofreeman/inventory-control/real-time-system/liquidity"
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+	"github.com/369geofreeman/inventory-control/real-time-system/okxconnector"
 	"github.com/369geofreeman/inventory-control/real-time-system/optimization"
+	"github.com/369geofreeman/inventory-control/real-time-system/recorder"
+	"github.com/369geofreeman/inventory-control/real-time-system/replay"
+	"github.com/369geofreeman/inventory-control/real-time-system/strategy"
+	"github.com/369geofreeman/inventory-control/real-time-system/volatility"
 )
 
 func main() {
+	venue := flag.String("venue", "bybit", "Venue to receive market data from: bybit, binance or okx")
+	symbol := flag.String("symbol", "BTCUSDT", "Symbol to quote, e.g. BTCUSDT or BTC-USDT-SWAP on OKX")
+	recordDir := flag.String("record", "", "Directory to record raw Bybit messages to, disabled if empty")
+	replayDir := flag.String("replay", "", "Directory of a Bybit recording to replay instead of connecting")
+	replaySpeed := flag.Float64("speed", 1, "Replay speed relative to the recording, 0 replays as fast as possible")
+	tickSize := flag.Float64("tick", 0.1, "Tick size of the symbol")
+	minInterval := flag.Duration("min-interval", 100*time.Millisecond, "Minimum time between two quotes, negative for none")
+	maxInterval := flag.Duration("max-interval", time.Minute, "Maximum time between two quotes")
+	moveTicks := flag.Float64("move-ticks", 1, "Fair value move in ticks that triggers a re-quote, negative for every update")
+	reference := flag.String("reference", "microprice", "Price to center quotes on: mid, weighted-mid or microprice")
+	liquidityBps := flag.Float64("liquidity-bps", 100, "Band around the mid-price in basis points that liquidity is measured within")
+	depthBps := flag.Float64("depth-bps", 500, "Band around the mid-price in basis points that depth is measured within")
+	depthMeasure := flag.String("depth-measure", "levels", "Measure of order book depth: levels, base or notional")
+	costNotional := flag.Float64("cost-notional", 10000, "Quote notional whose cost to trade at market is measured")
+	lpLiquidity := flag.String("lp-liquidity", "band", "Liquidity the lp strategy optimizes with: band or cost to trade -cost-notional")
+	volMethod := flag.String("vol-method", "realized", "Volatility estimator: realized, parkinson, garman-klass or ewma")
+	volInterval := flag.Duration("vol-interval", time.Second, "Sampling interval or bar length of the volatility estimator")
+	volWindow := flag.Int("vol-window", 60, "Number of returns or bars in the volatility window")
+	forecastMethod := flag.String("forecast", "garch", "Volatility forecast for the optimizer: garch, egarch or none")
+	forecastHorizon := flag.Duration("forecast-horizon", time.Minute, "Horizon the volatility forecast is averaged over")
+	strategyName := flag.String("strategy", "lp", "Quoting strategy: lp, avellaneda-stoikov or glft")
+	quoteSize := flag.Float64("size", 0.001, "Size of each quote in the base currency")
+	riskAversion := flag.Float64("gamma", 0.1, "Risk aversion of the avellaneda-stoikov and glft strategies")
+	fillIntensity := flag.Float64("a", 1, "Fills per second of a quote at the mid-price for the glft strategy")
+	intensityDecay := flag.Float64("k", 1.5, "Decay of the fill intensity with the distance of a quote from the mid-price")
+	calibrate := flag.Bool("calibrate", false, "Use the fill intensity calibrated from trades once ready instead of -a and -k")
+	intensityWindow := flag.Duration("intensity-window", 10*time.Minute, "Window of trades the fill intensity is calibrated over")
+	horizon := flag.Duration("horizon", 0, "Session length of the avellaneda-stoikov strategy, 0 for an infinite horizon")
+	maxInventory := flag.Float64("max-inventory", 0.01, "Maximum inventory in the base currency away from the initial balance")
+	statePath := flag.String("state", "", "File the optimizer state is restored from on start and saved to on shutdown, disabled if empty")
+	flag.Parse()
+
+	// Shut down cleanly on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	fmt.Println("Starting the real-time system...")
 
 	// Create an Inventory object with initial cash balance, crypto balance, and trading fee
@@ -18,37 +74,288 @@ func main() {
 	initialCryptoBalance := 0.12345
 	inventory := optimization.NewInventory(initialCashBalance, initialCryptoBalance, tradingFee)
 
-	// Establish connection to Bybit in a Goroutine
-	go func() {
-		err := bybitconnector.ConnectToBybit()
+	// Create the optimizer, resuming from the saved state if there is one
+	optimizer, err := optimization.NewOptimizer(optimization.DefaultParams())
+	if err != nil {
+		log.Fatalf("Invalid optimization parameters: %v", err)
+	}
+	if *statePath != "" {
+		if err := loadState(*statePath, optimizer); err != nil {
+			log.Fatalf("Error restoring optimizer state: %v", err)
+		}
+	}
+
+	// Record raw messages for backtesting if requested
+	var rec *recorder.Recorder
+	if *recordDir != "" {
+		if *venue != "bybit" {
+			log.Fatalf("Recording is only supported for bybit")
+		}
+		var err error
+		rec, err = recorder.New(*recordDir)
+		if err != nil {
+			log.Fatalf("Error creating recorder: %v", err)
+		}
+	}
+
+	// Connect to the venue, or replay a recording of it
+	var source marketdata.Source
+	var replaySource *replay.Source
+	var clk clock.Clock = clock.Real{}
+	if *replayDir != "" {
+		var sim *clock.Simulated
+		replaySource, sim, err = replay.NewSource(*replayDir, *replaySpeed, bybitconnector.Config{
+			Symbols:        []string{*symbol},
+			OrderBookDepth: 50,
+		})
+		if sim != nil {
+			source, clk = replaySource, sim
+		}
+	} else {
+		source, err = newSource(*venue, *symbol, rec)
+	}
+	if err != nil {
+		log.Fatalf("Invalid %s config: %v", *venue, err)
+	}
+	feed, _ := source.Feed(*symbol)
+
+	// Estimate volatility of the symbol's mid-price
+	estimator, err := volatility.New(volatility.Config{
+		Method:   volatility.Method(*volMethod),
+		Interval: *volInterval,
+		Window:   *volWindow,
+	})
+	if err != nil {
+		log.Fatalf("Invalid volatility config: %v", err)
+	}
+	feed.SetVolatilityEstimator(estimator)
+
+	// Center quotes on the selected fair value
+	fairValue, err := fairvalue.New(fairvalue.Config{Reference: fairvalue.Reference(*reference)})
+	if err != nil {
+		log.Fatalf("Invalid fair value config: %v", err)
+	}
+	feed.SetFairValue(fairValue)
+
+	// Measure liquidity and depth in the configured bands and the cost to trade
+	calculator, err := liquidity.New(liquidity.Config{
+		LiquidityBps:  *liquidityBps,
+		DepthBps:      *depthBps,
+		DepthMeasure:  liquidity.Measure(*depthMeasure),
+		CostNotionals: []float64{*costNotional},
+	})
+	if err != nil {
+		log.Fatalf("Invalid liquidity config: %v", err)
+	}
+	feed.SetLiquidity(calculator)
+
+	// Calibrate the fill intensity from how far trades reach from the mid-price
+	calibrator, err := intensity.New(intensity.Config{
+		Step:   *tickSize,
+		Window: *intensityWindow,
+	})
+	if err != nil {
+		log.Fatalf("Invalid intensity config: %v", err)
+	}
+	feed.SetIntensity(calibrator)
+
+	// Forecast volatility over the quoting horizon, refitted in the background
+	if *forecastMethod != "none" {
+		forecaster, err := volatility.New(volatility.Config{
+			Method:   volatility.Method(*forecastMethod),
+			Interval: *volInterval,
+			Horizon:  *forecastHorizon,
+		})
 		if err != nil {
-			log.Fatalf("Error connecting to Bybit: %v", err)
+			log.Fatalf("Invalid volatility forecast config: %v", err)
+		}
+		feed.SetVolatilityForecaster(forecaster)
+	}
+	// Quote with the selected model
+	quoting, err := newStrategy(*strategyName, optimizer, strategy.ASConfig{
+		Gamma:        *riskAversion,
+		K:            *intensityDecay,
+		Calibrated:   *calibrate,
+		Size:         *quoteSize,
+		Target:       initialCryptoBalance,
+		Horizon:      *horizon,
+		MaxInventory: *maxInventory,
+	}, strategy.LPConfig{
+		Size:         *quoteSize,
+		Liquidity:    strategy.LPLiquidity(*lpLiquidity),
+		CostNotional: *costNotional,
+	}, *fillIntensity)
+	if err != nil {
+		log.Fatalf("Invalid strategy config: %v", err)
+	}
+
+	// Re-quote whenever the market moves, the feed is reset on disconnect so
+	// quotes are pulled until it recovers
+	eng, err := engine.New(feed, &quoter{strategy: quoting, inventory: inventory, clock: clk}, engine.Config{
+		TickSize:       *tickSize,
+		MinInterval:    *minInterval,
+		MaxInterval:    *maxInterval,
+		PriceMoveTicks: *moveTicks,
+		LowVolatility:  0.5,
+		HighVolatility: 2.0,
+		Clock:          clk,
+	})
+	if err != nil {
+		log.Fatalf("Invalid engine config: %v", err)
+	}
+	if replaySource != nil {
+		// Evaluate every frame in order so a replay quotes the same way every time
+		replaySource.SetStep(eng.Step)
+		if err := source.Connect(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("Replay stopped: %v", err)
 		}
-	}()
+	} else {
+		connected := make(chan struct{})
+		go func() {
+			defer close(connected)
+			// Giving up on the venue leaves the feed reset, so the loop below keeps quotes pulled
+			err := source.Connect(ctx)
+			if !errors.Is(err, context.Canceled) {
+				log.Printf("Market data from %s unavailable, quotes pulled: %v", source.Venue(), err)
+			}
+		}()
+		if err := eng.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("Engine stopped: %v", err)
+		}
+		// The connector records every message, so it must stop before the recorder closes
+		<-connected
+	}
 
-	for {
-		// Wait until we have received at least one of each type of message
-		if !bybitconnector.IsOrderBookReady || !bybitconnector.IsTradeReady || !bybitconnector.IsTickerReady {
-			time.Sleep(5 * time.Second) // Wait for 5 seconds before checking again
-			continue
+	fmt.Println("Shutting down...")
+	if *statePath != "" {
+		if err := saveState(*statePath, optimizer); err != nil {
+			log.Printf("Error saving optimizer state: %v", err)
+		}
+	}
+	if rec != nil {
+		// Flush the recording before the process exits
+		if err := rec.Close(); err != nil {
+			log.Printf("Error closing recorder: %v", err)
 		}
+	}
+}
+
+// quoter asks the strategy for quotes on every market update the engine hands it
+type quoter struct {
+	strategy  strategy.Strategy
+	inventory *optimization.Inventory
+	clock     clock.Clock
+}
+
+// Quote re-quotes around the snapshot and executes against it
+func (q *quoter) Quote(snapshot marketdata.MarketSnapshot) {
+	cash, base := q.inventory.GetBalances()
+	quotes, err := q.strategy.Quote(strategy.Input{
+		Market:    snapshot,
+		Inventory: strategy.Inventory{Cash: cash, Base: base},
+		Now:       q.clock.Now(),
+	})
+	if err != nil {
+		q.pull(fmt.Sprintf("strategy %s failed: %v", q.strategy.Name(), err))
+		return
+	}
+	bid, okBid := quotes.BestBid()
+	ask, okAsk := quotes.BestAsk()
+	if !okBid && !okAsk {
+		q.pull(fmt.Sprintf("strategy %s quoted neither side", q.strategy.Name()))
+		return
+	}
+	if !okAsk {
+		// A missing bid is priced at 0 and a missing ask at +Inf so neither
+		// executes, e.g. at the max inventory
+		ask.Price = math.Inf(1)
+	}
+	currentPrice := snapshot.FairValue
+	fmt.Printf("Optimal Bid: %f\nOptimal Ask: %f\nPrice: %f\n", bid.Price, ask.Price, currentPrice)
+	log.Printf("Strategy %s diagnostics: %v", q.strategy.Name(), quotes.Diagnostics)
+
+	// Execute trades based on current price and optimal bid/ask
+	q.inventory.TradeExecuted(currentPrice, bid.Price, ask.Price)
+}
 
-		// Fetch market data
-		currentPrice := bybitconnector.MidPrice
-		volatility := bybitconnector.Volatility
-		liquidity := bybitconnector.Liquidity
-		orderBookDepth := bybitconnector.OrderBookDepth
+// Pull stops quoting until market data is ready again
+func (q *quoter) Pull() {
+	q.pull("market data not ready")
+}
+
+// pull cancels any resting quotes for the given reason
+func (q *quoter) pull(reason string) {
+	log.Printf("Pulling quotes, %s", reason)
+}
 
-		// Optimize spread, passing the inventory object
-		optimalAsk, optimalBid, _ := optimization.OptimizeSpread(currentPrice, inventory, volatility, liquidity, orderBookDepth)
-		fmt.Printf("Optimal Bid: %f\nOptimal Ask: %f\nPrice: %f\n", optimalBid, optimalAsk, currentPrice)
+// newStrategy creates the quoting strategy with the given name, the
+// avellaneda-stoikov config is shared with the glft strategy
+func newStrategy(name string, optimizer *optimization.Optimizer, config strategy.ASConfig, lp strategy.LPConfig, a float64) (strategy.Strategy, error) {
+	switch name {
+	case "lp":
+		return strategy.NewLP(optimizer, lp)
+	case "avellaneda-stoikov":
+		return strategy.NewAvellanedaStoikov(config)
+	case "glft":
+		return strategy.NewGLFT(strategy.GLFTConfig{
+			Gamma:        config.Gamma,
+			A:            a,
+			K:            config.K,
+			Size:         config.Size,
+			Calibrated:   config.Calibrated,
+			Target:       config.Target,
+			MaxInventory: config.MaxInventory,
+		})
+	}
+	return nil, fmt.Errorf("unknown strategy %q", name)
+}
+
+// loadState restores the optimizer from the state saved at path, a missing
+// file leaves the optimizer untouched
+func loadState(path string, optimizer *optimization.Optimizer) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state optimization.State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return optimizer.Restore(state)
+}
 
-		// Execute trades based on current price and optimal bid/ask
-		inventory.TradeExecuted(currentPrice, optimalBid, optimalAsk)
+// saveState writes the optimizer state to path
+func saveState(path string, optimizer *optimization.Optimizer) error {
+	data, err := json.MarshalIndent(optimizer.State(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
 
-		// Sleep for the determined time before the next optimization
-		sleepTime := optimization.GetOptimizationFrequency()
-		fmt.Printf("Time till next optimisation: %d\n", optimization.GetOptimizationFrequency())
-		time.Sleep(time.Duration(sleepTime) * time.Minute)
+// newSource creates the market data adapter for the given venue
+func newSource(venue, symbol string, rec *recorder.Recorder) (marketdata.Source, error) {
+	switch venue {
+	case "bybit":
+		config := bybitconnector.Config{
+			Symbols:        []string{symbol},
+			OrderBookDepth: 50,
+		}
+		if rec != nil {
+			config.Recorder = rec
+		}
+		return bybitconnector.NewClient(config)
+	case "binance":
+		return binanceconnector.NewClient(binanceconnector.Config{
+			Symbols: []string{symbol},
+		})
+	case "okx":
+		return okxconnector.NewClient(okxconnector.Config{
+			InstIDs: []string{symbol},
+		})
 	}
+	return nil, fmt.Errorf("unknown venue %q", venue)
 }
@@ -0,0 +1,442 @@
+package marketdata
+
+import (
+	"sync"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/clock"
+	"github.com/369geofreeman/inventory-control/real-time-system/fairvalue"
+	"github.com/369geofreeman/inventory-control/real-time-system/intensity"
+	"github.com/369geofreeman/inventory-control/real-time-system/liquidity"
+	"github.com/369geofreeman/inventory-control/real-time-system/orderbook"
+	"github.com/369geofreeman/inventory-control/real-time-system/volatility"
+)
+
+// Constants
+const recentTradePeriod = 50 // Number of recent trades to consider for trade flow
+
+var _ Handler = (*Feed)(nil)
+
+// Feed owns the market data state for a single symbol on a single venue and
+// derives the metrics used by the optimizer from normalized events.
+// Feed implements Handler and is safe for concurrent use.
+type Feed struct {
+	venue  string
+	symbol string
+	clock  clock.Clock // Source of local timestamps, simulated during replays
+
+	updates chan struct{} // Signalled after every change, coalesced while unread
+
+	mu           sync.Mutex
+	book         *orderbook.Book       // Local order book maintained from snapshots and deltas
+	recentTrades *TradeWindow          // Window of recent trades for trade flow
+	estimator    volatility.Estimator  // Volatility of the mid-price
+	forecaster   volatility.Estimator  // Optional forecast of mid-price volatility
+	fairValue    *fairvalue.Model      // Fair value signals derived from the book
+	liquidity    *liquidity.Calculator // Liquidity and depth measured around the mid-price
+	intensity    *intensity.Calibrator // Optional fill intensity calibrated from trades
+
+	midPrice     float64
+	fairPrice    float64
+	lastPrice    float64
+	volatility   float64
+	forecast     float64
+	bidLiquidity float64
+	askLiquidity float64
+	bidDepth     float64
+	askDepth     float64
+
+	// Derived metrics are only published while the book is valid, i.e. every
+	// update since the last snapshot was applied
+	orderBookValid bool
+
+	orderBookReady  bool
+	volatilityReady bool
+	tradeReady      bool
+	tickerReady     bool
+
+	orderBookTime time.Time // Exchange time of the last order book update
+	tradeTime     time.Time // Exchange time of the last trade
+	tickerTime    time.Time // Exchange time of the last ticker update
+	updatedAt     time.Time // Local time of the last update of any kind
+}
+
+// MarketSnapshot is a consistent copy of every metric published by a Feed
+type MarketSnapshot struct {
+	Venue  string
+	Symbol string
+
+	MidPrice       float64
+	FairValue      float64 // Reference price selected in the fair value config
+	LastPrice      float64
+	Volatility     float64 // Annualized volatility of mid-price log returns
+	Forecast       float64 // Annualized volatility forecast, 0 without a forecaster or until it is fitted
+	Liquidity      float64 // Mean of the bid and ask liquidity
+	OrderBookDepth float64 // Mean of the bid and ask depth
+
+	// Liquidity and depth per side, in the band and measure of the liquidity config
+	BidLiquidity float64
+	AskLiquidity float64
+	BidDepth     float64
+	AskDepth     float64
+
+	// Every band and cost to trade of the liquidity config
+	LiquidityMetrics liquidity.Metrics
+
+	// Fair value signals from the order book
+	WeightedMid        float64 // Best bid and ask weighted by the opposite size
+	Microprice         float64 // Mid adjusted by the expected next mid move
+	BookImbalance      float64 // (bid - ask) / (bid + ask) size over the top levels
+	OrderFlowImbalance float64 // Net top of book order flow over recent updates
+
+	// Trade flow over the window of recent trades
+	TradeVolume     float64 // Total size traded
+	BuyVolume       float64 // Size traded by buy aggressors
+	SellVolume      float64 // Size traded by sell aggressors
+	VolumeImbalance float64 // (buy - sell) / (buy + sell) volume
+	VWAP            float64 // Volume weighted average price
+	TradeRate       float64 // Trades per second
+
+	// Fill intensity A·exp(-kδ) of a quote on either side at a distance δ from the
+	// mid-price, zero without a calibrator or until IsIntensityReady
+	Intensity intensity.Estimate
+
+	IsOrderBookReady  bool
+	IsVolatilityReady bool
+	IsTradeReady      bool
+	IsTickerReady     bool
+	IsIntensityReady  bool // Not required by Ready, as the intensity is optional
+
+	OrderBookTime time.Time
+	TradeTime     time.Time
+	TickerTime    time.Time
+	UpdatedAt     time.Time
+}
+
+// Ready reports whether at least one of each type of message has been received,
+// the order book is in a consistent state and volatility has been estimated
+func (s MarketSnapshot) Ready() bool {
+	return s.IsOrderBookReady && s.IsVolatilityReady && s.IsTradeReady && s.IsTickerReady
+}
+
+// NewFeed creates a feed for the given venue and symbol
+func NewFeed(venue, symbol string) *Feed {
+	return &Feed{
+		venue:        venue,
+		symbol:       symbol,
+		clock:        clock.Real{},
+		book:         orderbook.New(),
+		recentTrades: NewTradeWindow(recentTradePeriod, 0),
+		estimator:    volatility.NewRealized(time.Second, 60),
+		fairValue:    defaultFairValue(),
+		liquidity:    defaultLiquidity(),
+		updates:      make(chan struct{}, 1),
+	}
+}
+
+// SetClock replaces the clock used for local timestamps. It must be called
+// before the feed receives any events.
+func (f *Feed) SetClock(c clock.Clock) {
+	f.clock = c
+}
+
+// SetVolatilityEstimator replaces the estimator of mid-price volatility, by
+// default the realized volatility of 1s returns over the last minute.
+// It must be called before the feed receives any events.
+func (f *Feed) SetVolatilityEstimator(e volatility.Estimator) {
+	f.estimator = e
+}
+
+// SetIntensity adds a calibrator of the fill intensity from the distance of
+// trades from the mid-price, published as Intensity. It must be called before
+// the feed receives any events.
+func (f *Feed) SetIntensity(c *intensity.Calibrator) {
+	f.intensity = c
+}
+
+// SetVolatilityForecaster adds a forecast of mid-price volatility, e.g. a
+// GARCH model, published as Forecast once it is ready. Readiness only depends
+// on the volatility estimator, so strategies fall back to Volatility until
+// the forecast is fitted. It must be called before the feed receives any events.
+func (f *Feed) SetVolatilityForecaster(e volatility.Estimator) {
+	f.forecaster = e
+}
+
+// defaultFairValue centers quotes on the mid-price
+func defaultFairValue() *fairvalue.Model {
+	m, _ := fairvalue.New(fairvalue.Config{})
+	return m
+}
+
+// defaultLiquidity measures the quantity within 1% and the number of levels
+// within 5% of the mid-price
+func defaultLiquidity() *liquidity.Calculator {
+	c, _ := liquidity.New(liquidity.Config{})
+	return c
+}
+
+// SetLiquidity replaces the liquidity calculator, e.g. to measure depth in
+// notional or the cost to trade. It must be called before the feed receives
+// any events.
+func (f *Feed) SetLiquidity(c *liquidity.Calculator) {
+	f.liquidity = c
+}
+
+// SetFairValue replaces the fair value model, e.g. to center quotes on the
+// microprice. It must be called before the feed receives any events.
+func (f *Feed) SetFairValue(m *fairvalue.Model) {
+	f.fairValue = m
+}
+
+// SetTradeWindow replaces the window of recent trades used for trade flow,
+// e.g. to use the last 60s of trades instead of the last 50. The order book
+// is reported ready once the window holds recentTradePeriod trades, or is
+// full if it is smaller. It must be called before the feed receives any events.
+func (f *Feed) SetTradeWindow(maxCount int, maxAge time.Duration) {
+	f.recentTrades = NewTradeWindow(maxCount, maxAge)
+}
+
+// Venue returns the venue the feed receives data from
+func (f *Feed) Venue() string {
+	return f.venue
+}
+
+// Symbol returns the symbol of the feed
+func (f *Feed) Symbol() string {
+	return f.symbol
+}
+
+// Updates returns a channel that is signalled whenever the metrics of the
+// feed change. Signals are coalesced, so a single receive may cover many
+// updates and consumers should read the latest state with Snapshot.
+func (f *Feed) Updates() <-chan struct{} {
+	return f.updates
+}
+
+// notify signals consumers without blocking the venue connection
+func (f *Feed) notify() {
+	select {
+	case f.updates <- struct{}{}:
+	default:
+	}
+}
+
+// Snapshot returns the current metrics of the feed. Trades that aged out of
+// the window since the last trade are evicted first, so trade flow decays
+// when the market goes quiet.
+func (f *Feed) Snapshot() MarketSnapshot {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.recentTrades.Prune(f.clock.Now())
+
+	return MarketSnapshot{
+		Venue:              f.venue,
+		Symbol:             f.symbol,
+		MidPrice:           f.midPrice,
+		FairValue:          f.fairPrice,
+		WeightedMid:        f.fairValue.WeightedMid(),
+		Microprice:         f.fairValue.Microprice(),
+		BookImbalance:      f.fairValue.Imbalance(),
+		OrderFlowImbalance: f.fairValue.OrderFlowImbalance(),
+		LastPrice:          f.lastPrice,
+		Volatility:         f.volatility,
+		Forecast:           f.forecast,
+		Liquidity:          (f.bidLiquidity + f.askLiquidity) / 2,
+		OrderBookDepth:     (f.bidDepth + f.askDepth) / 2,
+		BidLiquidity:       f.bidLiquidity,
+		AskLiquidity:       f.askLiquidity,
+		BidDepth:           f.bidDepth,
+		AskDepth:           f.askDepth,
+		LiquidityMetrics:   f.liquidity.Metrics(),
+		TradeVolume:        f.recentTrades.Volume(),
+		BuyVolume:          f.recentTrades.BuyVolume(),
+		SellVolume:         f.recentTrades.SellVolume(),
+		VolumeImbalance:    f.recentTrades.VolumeImbalance(),
+		VWAP:               f.recentTrades.VWAP(),
+		TradeRate:          f.recentTrades.ArrivalRate(),
+		Intensity:          f.intensityEstimate(),
+		IsIntensityReady:   f.intensity != nil && f.intensity.Ready(),
+		IsOrderBookReady:   f.orderBookReady,
+		IsVolatilityReady:  f.volatilityReady,
+		IsTradeReady:       f.tradeReady,
+		IsTickerReady:      f.tickerReady,
+		OrderBookTime:      f.orderBookTime,
+		TradeTime:          f.tradeTime,
+		TickerTime:         f.tickerTime,
+		UpdatedAt:          f.updatedAt,
+	}
+}
+
+// OnTicker updates the last traded price
+func (f *Feed) OnTicker(ticker Ticker) {
+	f.mu.Lock()
+	defer f.notify()
+	defer f.mu.Unlock()
+
+	if ticker.LastPrice != 0 {
+		f.lastPrice = ticker.LastPrice
+	}
+	f.tickerReady = true
+	f.tickerTime = ticker.ExchangeTime
+	f.updatedAt = f.clock.Now()
+
+	// log.Printf("LastPrice: %f", f.lastPrice)
+}
+
+// OnTrade adds a trade to the recent trades.
+// Block trades are negotiated off the order book, so they count as a trade
+// received but are left out of the window.
+func (f *Feed) OnTrade(trade Trade) {
+	f.mu.Lock()
+	defer f.notify()
+	defer f.mu.Unlock()
+
+	if !trade.BlockTrade {
+		f.recentTrades.Add(trade)
+
+		// Measure how far the trade reached from the mid-price it arrived at
+		if f.intensity != nil && f.orderBookValid && f.midPrice > 0 {
+			at := trade.ExchangeTime
+			if at.IsZero() {
+				at = f.clock.Now()
+			}
+			// A sell aggressor reaches the bids, a buy aggressor the asks
+			side := intensity.Ask
+			if trade.Side == Sell {
+				side = intensity.Bid
+			}
+			f.intensity.Add(at, side, trade.Price, f.midPrice)
+		}
+	}
+
+	f.tradeReady = true
+	f.tradeTime = trade.ExchangeTime
+	f.updatedAt = f.clock.Now()
+}
+
+// OnBookUpdate applies an order book snapshot or delta to the local book and
+// recalculates the mid-price, liquidity and order book depth from it.
+// Deltas received while the book is invalid are ignored.
+func (f *Feed) OnBookUpdate(update BookUpdate) {
+	f.mu.Lock()
+	defer f.notify()
+	defer f.mu.Unlock()
+
+	switch update.Type {
+	case Snapshot:
+		f.book.ApplySnapshot(update.Bids, update.Asks)
+		f.orderBookValid = true
+	case Delta:
+		if !f.orderBookValid {
+			// Waiting for a fresh snapshot
+			return
+		}
+		f.book.ApplyDelta(update.Bids, update.Asks)
+	}
+
+	f.orderBookTime = update.ExchangeTime
+	f.updatedAt = f.clock.Now()
+
+	mid, ok := f.book.MidPrice()
+	if !ok {
+		return
+	}
+	f.midPrice = mid
+	f.fairValue.Update(f.book)
+	f.fairPrice = f.fairValue.Reference()
+
+	// Calculate volatility from the mid-price sampled by exchange time, so
+	// replays estimate the same volatility as the live session
+	sampledAt := update.ExchangeTime
+	if sampledAt.IsZero() {
+		sampledAt = f.updatedAt
+	}
+	f.estimator.Update(sampledAt, mid)
+	if f.estimator.Ready() {
+		f.volatility = volatility.Annualized(f.estimator)
+	}
+	f.volatilityReady = f.estimator.Ready()
+	if f.forecaster != nil {
+		f.forecaster.Update(sampledAt, mid)
+		if f.forecaster.Ready() {
+			f.forecast = volatility.Annualized(f.forecaster)
+		}
+	}
+
+	// Calculate liquidity and depth in the bands around the mid-price, by
+	// default the quantity within 1% and the number of levels within 5%
+	f.liquidity.Update(f.book, f.midPrice)
+	f.bidLiquidity, f.askLiquidity = f.liquidity.Liquidity()
+	f.bidDepth, f.askDepth = f.liquidity.Depth()
+
+	// The order book is only reported as ready once there are enough trades for trade flow
+	if n := f.recentTrades.Len(); n >= recentTradePeriod || n == f.recentTrades.Cap() {
+		f.orderBookReady = true
+	}
+
+	// log.Printf("Liquidity: %f/%f, OrderBookDepth: %f/%f", f.bidLiquidity, f.askLiquidity, f.bidDepth, f.askDepth)
+}
+
+// Reset discards the book, recent trades and every derived metric, e.g. when
+// the venue disconnects and the data can no longer be trusted
+func (f *Feed) Reset() {
+	f.mu.Lock()
+	defer f.notify()
+	defer f.mu.Unlock()
+
+	f.book.Reset()
+	f.recentTrades.Reset()
+	f.estimator.Reset()
+	f.fairValue.Reset()
+	if f.forecaster != nil {
+		f.forecaster.Reset()
+	}
+	f.midPrice = 0
+	f.fairPrice = 0
+	f.lastPrice = 0
+	f.volatility = 0
+	f.forecast = 0
+	f.liquidity.Reset()
+	if f.intensity != nil {
+		f.intensity.Reset()
+	}
+	f.bidLiquidity = 0
+	f.askLiquidity = 0
+	f.bidDepth = 0
+	f.askDepth = 0
+	f.orderBookValid = false
+	f.orderBookReady = false
+	f.volatilityReady = false
+	f.tradeReady = false
+	f.tickerReady = false
+	f.updatedAt = f.clock.Now()
+}
+
+// intensityEstimate returns the fill intensity, or zero without a calibrator
+func (f *Feed) intensityEstimate() intensity.Estimate {
+	if f.intensity == nil {
+		return intensity.Estimate{}
+	}
+	return f.intensity.Estimate()
+}
+
+// OnBookInvalid stops publishing order book metrics until the next snapshot.
+// Every metric derived from the corrupted book is cleared, while volatility
+// and trade flow don't depend on its content and are kept.
+func (f *Feed) OnBookInvalid(symbol string) {
+	f.mu.Lock()
+	defer f.notify()
+	defer f.mu.Unlock()
+
+	f.orderBookValid = false
+	f.orderBookReady = false
+	f.fairValue.Reset()
+	f.liquidity.Reset()
+	f.midPrice = 0
+	f.fairPrice = 0
+	f.bidLiquidity = 0
+	f.askLiquidity = 0
+	f.bidDepth = 0
+	f.askDepth = 0
+}
@@ -0,0 +1,117 @@
+package marketdata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/clock"
+	"github.com/369geofreeman/inventory-control/real-time-system/orderbook"
+	"github.com/369geofreeman/inventory-control/real-time-system/volatility"
+)
+
+func TestBookInvalidClearsBookMetrics(t *testing.T) {
+	f := NewFeed("test", "BTCUSDT")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	snapshot := BookUpdate{
+		Type:         Snapshot,
+		Bids:         []orderbook.Level{{Price: 99.9, Size: 2}, {Price: 99.5, Size: 3}},
+		Asks:         []orderbook.Level{{Price: 100.1, Size: 1}, {Price: 100.5, Size: 4}},
+		ExchangeTime: start,
+	}
+	f.OnBookUpdate(snapshot)
+	f.OnTrade(Trade{Price: 100, Size: 1, Side: Buy, ExchangeTime: start})
+
+	s := f.Snapshot()
+	if s.MidPrice != 100 || s.Liquidity == 0 || s.OrderBookDepth == 0 {
+		t.Fatalf("Expected metrics from the book, got %+v", s)
+	}
+
+	// Nothing derived from the corrupted book is published, even before the
+	// next update
+	f.OnBookInvalid("BTCUSDT")
+	s = f.Snapshot()
+	if s.MidPrice != 0 || s.FairValue != 0 || s.WeightedMid != 0 || s.Microprice != 0 {
+		t.Errorf("Expected no prices from an invalid book, got %+v", s)
+	}
+	if s.Liquidity != 0 || s.OrderBookDepth != 0 || s.BidLiquidity != 0 || s.AskLiquidity != 0 ||
+		s.BidDepth != 0 || s.AskDepth != 0 {
+		t.Errorf("Expected no liquidity from an invalid book, got %+v", s)
+	}
+	for _, band := range s.LiquidityMetrics.Bands {
+		if band.Bid.Base != 0 || band.Ask.Base != 0 {
+			t.Errorf("Expected no liquidity in the %g bps band, got %+v", band.Bps, band)
+		}
+	}
+	if s.IsOrderBookReady {
+		t.Error("Expected the order book not to be ready")
+	}
+	if s.TradeVolume != 1 {
+		t.Errorf("Expected the trades to be kept, got volume %f", s.TradeVolume)
+	}
+
+	// Deltas are ignored until the next snapshot
+	f.OnBookUpdate(BookUpdate{Type: Delta, Bids: []orderbook.Level{{Price: 99.95, Size: 1}}})
+	if mid := f.Snapshot().MidPrice; mid != 0 {
+		t.Errorf("Expected a delta not to publish a mid-price, got %f", mid)
+	}
+	f.OnBookUpdate(snapshot)
+	if mid := f.Snapshot().MidPrice; mid != 100 {
+		t.Errorf("Expected the mid-price after the next snapshot, got %f", mid)
+	}
+}
+
+func TestSnapshotPrunesQuietTradeWindow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := clock.NewSimulated(start)
+	f := NewFeed("test", "BTCUSDT")
+	f.SetClock(c)
+	f.SetTradeWindow(100, time.Minute)
+
+	f.OnTrade(Trade{Price: 100, Size: 1, Side: Buy, ExchangeTime: start})
+	c.Advance(time.Second)
+	f.OnTrade(Trade{Price: 101, Size: 2, Side: Sell, ExchangeTime: start.Add(time.Second)})
+	if s := f.Snapshot(); s.TradeVolume != 3 || s.TradeRate != 1 {
+		t.Fatalf("Expected volume 3 at 1 trade per second, got %f at %f", s.TradeVolume, s.TradeRate)
+	}
+
+	// No more trades arrive, so the window empties as the clock moves on
+	c.Advance(time.Minute)
+	if s := f.Snapshot(); s.TradeVolume != 2 || s.SellVolume != 2 {
+		t.Errorf("Expected only the newest trade after a minute, got volume %f", s.TradeVolume)
+	}
+	c.Advance(time.Minute)
+	s := f.Snapshot()
+	if s.TradeVolume != 0 || s.VWAP != 0 || s.TradeRate != 0 || s.VolumeImbalance != 0 {
+		t.Errorf("Expected no trade flow once the market went quiet, got %+v", s)
+	}
+}
+
+func TestForecastDoesNotGateReadiness(t *testing.T) {
+	f := NewFeed("test", "BTCUSDT")
+	f.SetVolatilityEstimator(volatility.NewRealized(time.Millisecond, 2))
+	fit := func(returns []float64) (volatility.Model, error) {
+		return volatility.FitGARCH(returns)
+	}
+	// The forecaster needs 500 returns before its first fit
+	f.SetVolatilityForecaster(volatility.NewForecaster(fit, time.Millisecond, 500, 100, 10))
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		mid := 100 + float64(i%2)*0.1
+		f.OnBookUpdate(BookUpdate{
+			Type:         Snapshot,
+			Bids:         []orderbook.Level{{Price: mid - 0.5, Size: 1}},
+			Asks:         []orderbook.Level{{Price: mid + 0.5, Size: 1}},
+			ExchangeTime: start.Add(time.Duration(i) * time.Millisecond),
+		})
+	}
+
+	// Until the forecast is fitted strategies quote on the realized volatility
+	s := f.Snapshot()
+	if !s.IsVolatilityReady || s.Volatility <= 0 {
+		t.Errorf("Expected the realized volatility to be ready, got %+v", s)
+	}
+	if s.Forecast != 0 {
+		t.Errorf("Expected no forecast before the first fit, got %f", s.Forecast)
+	}
+}
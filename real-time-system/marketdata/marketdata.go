@@ -0,0 +1,93 @@
+package marketdata
+
+import (
+	"context"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/orderbook"
+)
+
+// Side is the aggressor side of a trade
+type Side int
+
+const (
+	Buy Side = iota
+	Sell
+)
+
+func (s Side) String() string {
+	if s == Buy {
+		return "Buy"
+	}
+	return "Sell"
+}
+
+// BookUpdateType tells whether a book update replaces the book or changes it
+type BookUpdateType int
+
+const (
+	Snapshot BookUpdateType = iota // Replaces the whole book
+	Delta                          // Changes individual levels, a size of 0 removes the level
+)
+
+// BookUpdate is a venue-neutral order book snapshot or delta. Venue adapters
+// are responsible for sequencing and only publish updates that apply cleanly.
+type BookUpdate struct {
+	Venue        string
+	Symbol       string
+	Type         BookUpdateType
+	Bids         []orderbook.Level
+	Asks         []orderbook.Level
+	ExchangeTime time.Time
+}
+
+// Trade is a venue-neutral public trade
+type Trade struct {
+	Venue        string
+	Symbol       string
+	Price        float64
+	Size         float64
+	Side         Side
+	BlockTrade   bool
+	ExchangeTime time.Time
+}
+
+// Ticker is a venue-neutral ticker update. Fields the venue did not send in
+// this update are left at zero.
+type Ticker struct {
+	Venue        string
+	Symbol       string
+	LastPrice    float64
+	MarkPrice    float64
+	IndexPrice   float64
+	BestBid      float64
+	BestBidSize  float64
+	BestAsk      float64
+	BestAskSize  float64
+	ExchangeTime time.Time
+}
+
+// Handler receives normalized market data events from a venue adapter
+type Handler interface {
+	OnBookUpdate(update BookUpdate)
+	OnTrade(trade Trade)
+	OnTicker(ticker Ticker)
+	// OnBookInvalid is called when the adapter can no longer guarantee the
+	// book is correct, e.g. after a sequence gap or a disconnect. The next
+	// update for the symbol will be a Snapshot.
+	OnBookInvalid(symbol string)
+}
+
+// Source is implemented by every venue adapter. It publishes normalized
+// events into one Feed per symbol.
+type Source interface {
+	// Venue returns the name of the venue, e.g. "bybit"
+	Venue() string
+	// Feed returns the feed for the given symbol
+	Feed(symbol string) (*Feed, bool)
+	// Feeds returns every feed in the order the symbols were configured
+	Feeds() []*Feed
+	// Connect connects to the venue and publishes events until it gives up
+	// or ctx is cancelled
+	Connect(ctx context.Context) error
+}
@@ -0,0 +1,175 @@
+package marketdata
+
+import (
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/ring"
+)
+
+// TradeWindow holds the most recent trades, bounded by count and optionally
+// by age, and keeps rolling statistics of their prices and volumes in O(1)
+// per trade.
+// TradeWindow is not safe for concurrent use.
+type TradeWindow struct {
+	trades *ring.Buffer[Trade]
+	maxAge time.Duration // Trades older than this relative to the newest trade or Prune are evicted, 0 disables
+	prices ring.Stats
+
+	buyVolume  float64
+	sellVolume float64
+	notional   float64 // Sum of price * size for the VWAP
+
+	// Rolling removal accumulates rounding error, so the statistics are
+	// rebuilt from the buffer after every Cap evictions
+	evictions int
+}
+
+// NewTradeWindow creates a window holding at most maxCount trades no older
+// than maxAge, judged by exchange time. A maxAge of 0 bounds by count only.
+func NewTradeWindow(maxCount int, maxAge time.Duration) *TradeWindow {
+	return &TradeWindow{
+		trades: ring.NewBuffer[Trade](maxCount),
+		maxAge: maxAge,
+	}
+}
+
+// Add adds a trade as the newest one and evicts trades outside the window
+func (w *TradeWindow) Add(trade Trade) {
+	if evicted, ok := w.trades.Push(trade); ok {
+		w.evict(evicted)
+	}
+	w.include(trade)
+	w.Prune(trade.ExchangeTime)
+}
+
+// Prune evicts trades older than maxAge at now. Add only ages the window
+// against the newest trade, so readers call Prune with the current time for
+// a window that stops receiving trades to empty out.
+func (w *TradeWindow) Prune(now time.Time) {
+	if w.maxAge > 0 {
+		cutoff := now.Add(-w.maxAge)
+		for {
+			oldest, ok := w.trades.Front()
+			if !ok || !oldest.ExchangeTime.Before(cutoff) {
+				break
+			}
+			w.trades.PopFront()
+			w.evict(oldest)
+		}
+	}
+
+	if w.evictions >= w.trades.Cap() {
+		w.resetStats()
+		w.trades.Do(w.include)
+	}
+}
+
+// include adds a trade to the statistics
+func (w *TradeWindow) include(trade Trade) {
+	w.prices.Add(trade.Price)
+	w.notional += trade.Price * trade.Size
+	if trade.Side == Sell {
+		w.sellVolume += trade.Size
+	} else {
+		w.buyVolume += trade.Size
+	}
+}
+
+// evict removes a trade that left the buffer from the statistics
+func (w *TradeWindow) evict(trade Trade) {
+	w.prices.Remove(trade.Price)
+	w.notional -= trade.Price * trade.Size
+	if trade.Side == Sell {
+		w.sellVolume -= trade.Size
+	} else {
+		w.buyVolume -= trade.Size
+	}
+	w.evictions++
+}
+
+// resetStats clears the statistics without touching the trades
+func (w *TradeWindow) resetStats() {
+	w.prices.Reset()
+	w.buyVolume = 0
+	w.sellVolume = 0
+	w.notional = 0
+	w.evictions = 0
+}
+
+// Len returns the number of trades in the window
+func (w *TradeWindow) Len() int {
+	return w.trades.Len()
+}
+
+// Cap returns the maximum number of trades in the window
+func (w *TradeWindow) Cap() int {
+	return w.trades.Cap()
+}
+
+// Do calls f for every trade from oldest to newest
+func (w *TradeWindow) Do(f func(Trade)) {
+	w.trades.Do(f)
+}
+
+// MeanPrice returns the mean price of the trades in the window
+func (w *TradeWindow) MeanPrice() float64 {
+	return w.prices.Mean()
+}
+
+// PriceStdDev returns the population standard deviation of the trade prices
+func (w *TradeWindow) PriceStdDev() float64 {
+	return w.prices.StdDev()
+}
+
+// Volume returns the total size traded in the window
+func (w *TradeWindow) Volume() float64 {
+	return w.buyVolume + w.sellVolume
+}
+
+// BuyVolume returns the size traded by buy aggressors in the window
+func (w *TradeWindow) BuyVolume() float64 {
+	return w.buyVolume
+}
+
+// SellVolume returns the size traded by sell aggressors in the window
+func (w *TradeWindow) SellVolume() float64 {
+	return w.sellVolume
+}
+
+// VolumeImbalance returns (buy - sell) / (buy + sell) volume, between -1 when
+// every trade was sold into and 1 when every trade was bought, 0 if empty
+func (w *TradeWindow) VolumeImbalance() float64 {
+	volume := w.Volume()
+	if volume <= 0 {
+		return 0
+	}
+	return (w.buyVolume - w.sellVolume) / volume
+}
+
+// VWAP returns the volume weighted average price of the window, 0 if there
+// is no volume
+func (w *TradeWindow) VWAP() float64 {
+	volume := w.Volume()
+	if volume <= 0 {
+		return 0
+	}
+	return w.notional / volume
+}
+
+// ArrivalRate returns the number of trades per second between the oldest and
+// newest trade in the window, 0 until they are apart in time
+func (w *TradeWindow) ArrivalRate() float64 {
+	oldest, _ := w.trades.Front()
+	newest, _ := w.trades.Back()
+	span := newest.ExchangeTime.Sub(oldest.ExchangeTime).Seconds()
+	if span <= 0 {
+		return 0
+	}
+	return float64(w.trades.Len()-1) / span
+}
+
+// Reset removes every trade
+func (w *TradeWindow) Reset() {
+	w.trades.Reset()
+	w.resetStats()
+}
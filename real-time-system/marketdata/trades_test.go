@@ -0,0 +1,177 @@
+package marketdata
+
+import (
+	"container/list"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTradeWindowEvictsByCount(t *testing.T) {
+	w := NewTradeWindow(3, 0)
+	for _, price := range []float64{100, 101, 102, 103} {
+		w.Add(Trade{Price: price})
+	}
+
+	if w.Len() != 3 {
+		t.Errorf("Expected 3 trades, got %d", w.Len())
+	}
+	if w.MeanPrice() != 102 {
+		t.Errorf("Expected mean price 102, got %f", w.MeanPrice())
+	}
+	if expected := math.Sqrt(2.0 / 3); math.Abs(w.PriceStdDev()-expected) > 1e-9 {
+		t.Errorf("Expected std dev %f, got %f", expected, w.PriceStdDev())
+	}
+}
+
+func TestTradeWindowEvictsByAge(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := NewTradeWindow(100, time.Minute)
+	w.Add(Trade{Price: 100, Size: 1, Side: Buy, ExchangeTime: start})
+	w.Add(Trade{Price: 110, Size: 2, Side: Sell, ExchangeTime: start.Add(30 * time.Second)})
+	w.Add(Trade{Price: 120, Size: 3, Side: Buy, ExchangeTime: start.Add(61 * time.Second)})
+
+	var sides []Side
+	w.Do(func(trade Trade) { sides = append(sides, trade.Side) })
+	if len(sides) != 2 || sides[0] != Sell || sides[1] != Buy {
+		t.Errorf("Expected the trade older than a minute to be evicted, got sides %v", sides)
+	}
+	if w.MeanPrice() != 115 {
+		t.Errorf("Expected mean price 115, got %f", w.MeanPrice())
+	}
+
+	w.Reset()
+	if w.Len() != 0 || w.MeanPrice() != 0 {
+		t.Errorf("Expected an empty window after reset, got %d trades", w.Len())
+	}
+}
+
+func TestTradeWindowPrune(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := NewTradeWindow(100, time.Minute)
+	w.Add(Trade{Price: 100, Size: 1, Side: Buy, ExchangeTime: start})
+	w.Add(Trade{Price: 110, Size: 2, Side: Sell, ExchangeTime: start.Add(30 * time.Second)})
+
+	// No trade arrives to age the window, so only pruning empties it
+	w.Prune(start.Add(61 * time.Second))
+	if w.Len() != 1 || w.Volume() != 2 {
+		t.Errorf("Expected the trade older than a minute to be evicted, got %d trades", w.Len())
+	}
+	w.Prune(start.Add(2 * time.Minute))
+	if w.Len() != 0 || w.Volume() != 0 || w.MeanPrice() != 0 || w.ArrivalRate() != 0 {
+		t.Errorf("Expected an empty window once the market went quiet, got %d trades", w.Len())
+	}
+
+	// Without a maximum age trades are only evicted by count
+	w = NewTradeWindow(3, 0)
+	w.Add(Trade{Price: 100, ExchangeTime: start})
+	w.Prune(start.Add(time.Hour))
+	if w.Len() != 1 {
+		t.Errorf("Expected the trade to be kept without a maximum age, got %d trades", w.Len())
+	}
+}
+
+func TestTradeWindowTradeFlow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := NewTradeWindow(3, 0)
+	trades := []Trade{
+		{Price: 90, Size: 10, Side: Sell, ExchangeTime: start},
+		{Price: 100, Size: 1, Side: Buy, ExchangeTime: start.Add(time.Second)},
+		{Price: 102, Size: 3, Side: Sell, ExchangeTime: start.Add(2 * time.Second)},
+		{Price: 104, Size: 4, Side: Buy, ExchangeTime: start.Add(5 * time.Second)},
+	}
+	for _, trade := range trades {
+		w.Add(trade)
+	}
+
+	// The first trade was evicted
+	if w.Volume() != 8 || w.BuyVolume() != 5 || w.SellVolume() != 3 {
+		t.Errorf("Expected volumes 8/5/3, got %f/%f/%f", w.Volume(), w.BuyVolume(), w.SellVolume())
+	}
+	if w.VolumeImbalance() != 0.25 {
+		t.Errorf("Expected imbalance 0.25, got %f", w.VolumeImbalance())
+	}
+	if expected := (100.0 + 306 + 416) / 8; math.Abs(w.VWAP()-expected) > 1e-9 {
+		t.Errorf("Expected VWAP %f, got %f", expected, w.VWAP())
+	}
+	// Two intervals over four seconds
+	if w.ArrivalRate() != 0.5 {
+		t.Errorf("Expected 0.5 trades per second, got %f", w.ArrivalRate())
+	}
+
+	w.Reset()
+	if w.VWAP() != 0 || w.VolumeImbalance() != 0 || w.ArrivalRate() != 0 {
+		t.Error("Expected no trade flow after reset")
+	}
+}
+
+func TestTradeWindowDoesNotDrift(t *testing.T) {
+	w := NewTradeWindow(recentTradePeriod, 0)
+	for i := 0; i < 100000; i++ {
+		w.Add(Trade{Price: 30000 + float64(i%7)*0.5})
+	}
+	// Recompute directly from the trades left in the window
+	var sum, sumOfSquares float64
+	w.Do(func(trade Trade) { sum += trade.Price })
+	mean := sum / float64(w.Len())
+	w.Do(func(trade Trade) { sumOfSquares += (trade.Price - mean) * (trade.Price - mean) })
+	expected := math.Sqrt(sumOfSquares / float64(w.Len()))
+
+	if math.Abs(w.PriceStdDev()-expected) > 1e-9 {
+		t.Errorf("Expected std dev %.12f, got %.12f", expected, w.PriceStdDev())
+	}
+}
+
+// listVolatility is the previous implementation, which rescanned a list of
+// boxed prices on every trade
+func listVolatility(trades *list.List, price float64) float64 {
+	trades.PushFront(price)
+	if trades.Len() > recentTradePeriod {
+		trades.Remove(trades.Back())
+	}
+	var sum float64
+	var sumOfSquares float64
+	for e := trades.Front(); e != nil; e = e.Next() {
+		val := e.Value.(float64)
+		sum += val
+		sumOfSquares += val * val
+	}
+	mean := sum / float64(trades.Len())
+	return math.Sqrt(sumOfSquares/float64(trades.Len()) - mean*mean)
+}
+
+func BenchmarkListWindow(b *testing.B) {
+	trades := list.New()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		listVolatility(trades, 30000+float64(i%7)*0.5)
+	}
+}
+
+func BenchmarkTradeWindow(b *testing.B) {
+	w := NewTradeWindow(recentTradePeriod, 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w.Add(Trade{Price: 30000 + float64(i%7)*0.5})
+		w.PriceStdDev()
+	}
+}
+
+func BenchmarkTradeWindowByAge(b *testing.B) {
+	start := time.Now()
+	w := NewTradeWindow(10000, time.Minute)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		// Ten trades per second keeps about 600 trades in the window
+		w.Add(Trade{Price: 30000 + float64(i%7)*0.5, ExchangeTime: start.Add(time.Duration(i) * 100 * time.Millisecond)})
+		w.PriceStdDev()
+	}
+}
+
+func BenchmarkFeedOnTrade(b *testing.B) {
+	feed := NewFeed("bench", "BTCUSDT")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		feed.OnTrade(Trade{Price: 30000 + float64(i%7)*0.5})
+	}
+}
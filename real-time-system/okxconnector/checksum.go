@@ -0,0 +1,78 @@
+package okxconnector
+
+import (
+	"hash/crc32"
+	"strings"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/orderbook"
+)
+
+const checksumLevels = 25 // Levels per side included in the checksum
+
+// levelText keeps the price and size of a level exactly as OKX sent them,
+// since the checksum is calculated over the original strings
+type levelText struct {
+	price string
+	size  string
+}
+
+// checkedBook is a local copy of an OKX order book used to validate the
+// checksum of every update before it is published
+type checkedBook struct {
+	book *orderbook.Book
+	text [2]map[float64]levelText // Original strings by price for bids and asks
+}
+
+func newCheckedBook() *checkedBook {
+	return &checkedBook{
+		book: orderbook.New(),
+		text: [2]map[float64]levelText{{}, {}},
+	}
+}
+
+// apply updates the book with the given levels, replacing it for snapshots
+func (b *checkedBook) apply(snapshot bool, bids, asks [][]string) {
+	if snapshot {
+		b.book.Reset()
+		b.text = [2]map[float64]levelText{{}, {}}
+	}
+	b.book.ApplyDelta(b.applyText(orderbook.Bid, bids), b.applyText(orderbook.Ask, asks))
+}
+
+// applyText records the original strings of each level and returns the parsed levels
+func (b *checkedBook) applyText(side orderbook.Side, raw [][]string) []orderbook.Level {
+	levels := make([]orderbook.Level, 0, len(raw))
+	for _, text := range raw {
+		if len(text) < 2 {
+			continue
+		}
+		level := orderbook.Level{Price: parseFloat(text[0]), Size: parseFloat(text[1])}
+		if level.Size <= 0 {
+			delete(b.text[side], level.Price)
+		} else {
+			b.text[side][level.Price] = levelText{price: text[0], size: text[1]}
+		}
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// checksum calculates OKX's CRC32 checksum over the best 25 levels of each
+// side, alternating bid and ask as "price:size" pairs joined by ':'
+func (b *checkedBook) checksum() int32 {
+	bids := b.book.Levels(orderbook.Bid, checksumLevels)
+	asks := b.book.Levels(orderbook.Ask, checksumLevels)
+
+	parts := make([]string, 0, 2*(len(bids)+len(asks)))
+	for i := 0; i < checksumLevels; i++ {
+		if i < len(bids) {
+			text := b.text[orderbook.Bid][bids[i].Price]
+			parts = append(parts, text.price, text.size)
+		}
+		if i < len(asks) {
+			text := b.text[orderbook.Ask][asks[i].Price]
+			parts = append(parts, text.price, text.size)
+		}
+	}
+	return int32(crc32.ChecksumIEEE([]byte(strings.Join(parts, ":"))))
+}
@@ -0,0 +1,54 @@
+package okxconnector
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	book := newCheckedBook()
+	book.apply(true,
+		[][]string{{"3366.1", "7", "0", "3"}, {"3366.8", "9", "0", "1"}},
+		[][]string{{"3366.9", "7", "0", "2"}, {"3367", "3", "0", "1"}},
+	)
+
+	// Bids and asks alternate best first: "3366.8:9:3366.9:7:3366.1:7:3367:3"
+	if checksum := book.checksum(); checksum != 699331730 {
+		t.Errorf("Expected checksum 699331730, got %d", checksum)
+	}
+
+	// Removing a level drops it from the checksum
+	book.apply(false, nil, [][]string{{"3367", "0", "0", "0"}})
+	if checksum := book.checksum(); checksum != 1170974787 {
+		t.Errorf("Expected checksum 1170974787, got %d", checksum)
+	}
+}
+
+func TestProcessBookChecksumMismatch(t *testing.T) {
+	sub := newSubscription("BTC-USDT-SWAP")
+	snapshot := `[{"bids":[["3366.8","9","0","1"],["3366.1","7","0","3"]],"asks":[["3366.9","7","0","2"],["3367","3","0","1"]],"ts":"1597026383085","checksum":699331730,"prevSeqId":-1,"seqId":10}]`
+	if err := sub.ProcessBook("snapshot", json.RawMessage(snapshot)); err != nil {
+		t.Fatalf("Unexpected error applying snapshot: %v", err)
+	}
+	if mid := sub.feed.Snapshot().MidPrice; math.Abs(mid-3366.85) > 1e-9 {
+		t.Errorf("Expected mid price 3366.85, got %f", mid)
+	}
+
+	// The update removes the 3367 ask but claims the checksum is unchanged
+	update := `[{"bids":[],"asks":[["3367","0","0","0"]],"ts":"1597026383086","checksum":699331730,"prevSeqId":10,"seqId":11}]`
+	err := sub.ProcessBook("update", json.RawMessage(update))
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Expected a checksum mismatch, got %v", err)
+	}
+	if sub.synced {
+		t.Error("Expected the subscription to be out of sync")
+	}
+
+	// Updates are dropped until the next snapshot
+	update = `[{"bids":[],"asks":[],"ts":"1597026383087","checksum":0,"prevSeqId":11,"seqId":12}]`
+	if err := sub.ProcessBook("update", json.RawMessage(update)); err != nil {
+		t.Errorf("Expected update to be dropped, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package okxconnector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+	"github.com/369geofreeman/inventory-control/real-time-system/orderbook"
+)
+
+const venue = "okx"
+
+var (
+	// ErrSequenceGap is returned when a book update does not follow the previous one
+	ErrSequenceGap = errors.New("order book sequence gap")
+	// ErrChecksumMismatch is returned when the local book does not match OKX's checksum
+	ErrChecksumMismatch = errors.New("order book checksum mismatch")
+)
+
+// subscription holds the OKX specific state for a single instrument and the
+// feed its normalized events are published to
+type subscription struct {
+	instID string
+	feed   *marketdata.Feed
+
+	// The local book is validated against OKX's checksum before every update
+	// is published. Updates are only forwarded while synced.
+	book      *checkedBook
+	synced    bool
+	lastSeqID int64
+}
+
+func newSubscription(instID string) *subscription {
+	return &subscription{
+		instID: instID,
+		feed:   marketdata.NewFeed(venue, instID),
+		book:   newCheckedBook(),
+	}
+}
+
+func (s *subscription) args() []Arg {
+	return []Arg{
+		{Channel: "books", InstID: s.instID},
+		{Channel: "trades", InstID: s.instID},
+		{Channel: "tickers", InstID: s.instID},
+	}
+}
+
+// ProcessTickers normalizes the ticker data and publishes it to the feed
+func (s *subscription) ProcessTickers(data []TickerData) {
+	for _, ticker := range data {
+		s.feed.OnTicker(marketdata.Ticker{
+			Venue:        venue,
+			Symbol:       s.instID,
+			LastPrice:    parseFloat(ticker.Last),
+			BestBid:      parseFloat(ticker.BidPrice),
+			BestBidSize:  parseFloat(ticker.BidSize),
+			BestAsk:      parseFloat(ticker.AskPrice),
+			BestAskSize:  parseFloat(ticker.AskSize),
+			ExchangeTime: parseTimestamp(ticker.Timestamp),
+		})
+	}
+}
+
+// ProcessTrades normalizes the trade data and publishes it to the feed
+func (s *subscription) ProcessTrades(data []TradeData) {
+	for _, trade := range data {
+		side := marketdata.Buy
+		if trade.Side == "sell" {
+			side = marketdata.Sell
+		}
+
+		s.feed.OnTrade(marketdata.Trade{
+			Venue:        venue,
+			Symbol:       s.instID,
+			Price:        parseFloat(trade.Price),
+			Size:         parseFloat(trade.Size),
+			Side:         side,
+			ExchangeTime: parseTimestamp(trade.Timestamp),
+		})
+	}
+}
+
+// ProcessBook applies a books snapshot or update to the local book, validates
+// its sequence and checksum and publishes it to the feed. It returns
+// ErrSequenceGap or ErrChecksumMismatch when the book has to be resubscribed,
+// after which updates are dropped until the next snapshot arrives.
+func (s *subscription) ProcessBook(action string, raw json.RawMessage) error {
+	var data []BookData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		log.Println("Error parsing order book:", err)
+		return nil
+	}
+
+	for _, book := range data {
+		update := marketdata.BookUpdate{
+			Venue:        venue,
+			Symbol:       s.instID,
+			Bids:         parseLevels(book.Bids),
+			Asks:         parseLevels(book.Asks),
+			ExchangeTime: parseTimestamp(book.Timestamp),
+		}
+
+		switch action {
+		case "snapshot":
+			update.Type = marketdata.Snapshot
+			s.synced = true
+
+		case "update":
+			if !s.synced {
+				// Waiting for a fresh snapshot
+				continue
+			}
+			if book.PrevSeqID != s.lastSeqID {
+				s.invalidate()
+				return fmt.Errorf("%w: previous sequence ID %d, expected %d", ErrSequenceGap, book.PrevSeqID, s.lastSeqID)
+			}
+			update.Type = marketdata.Delta
+
+		default:
+			log.Println("Unknown order book action:", action)
+			continue
+		}
+
+		s.book.apply(update.Type == marketdata.Snapshot, book.Bids, book.Asks)
+		if checksum := s.book.checksum(); checksum != book.Checksum {
+			s.invalidate()
+			return fmt.Errorf("%w: got %d, expected %d", ErrChecksumMismatch, checksum, book.Checksum)
+		}
+
+		s.lastSeqID = book.SeqID
+		s.feed.OnBookUpdate(update)
+	}
+	return nil
+}
+
+// invalidate stops publishing order book metrics until the next snapshot
+func (s *subscription) invalidate() {
+	s.synced = false
+	s.feed.OnBookInvalid(s.instID)
+}
+
+// reset discards all sequencing state and the feed, e.g. after a disconnect
+func (s *subscription) reset() {
+	s.synced = false
+	s.lastSeqID = 0
+	s.feed.Reset()
+}
+
+// parseLevels converts OKX [price, size, ...] string arrays into order book levels
+func parseLevels(raw [][]string) []orderbook.Level {
+	levels := make([]orderbook.Level, 0, len(raw))
+	for _, level := range raw {
+		if len(level) < 2 {
+			continue
+		}
+		levels = append(levels, orderbook.Level{
+			Price: parseFloat(level[0]),
+			Size:  parseFloat(level[1]),
+		})
+	}
+	return levels
+}
+
+// parseTimestamp converts a millisecond timestamp string into a time
+func parseTimestamp(s string) time.Time {
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		log.Println("Error parsing timestamp:", err)
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+func parseFloat(s string) float64 {
+	val, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		log.Println("Error parsing float:", err)
+		return 0
+	}
+	return val
+}
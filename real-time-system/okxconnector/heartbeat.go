@@ -0,0 +1,20 @@
+package okxconnector
+
+import (
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/wsconn"
+)
+
+const (
+	defaultPingInterval = 20 * time.Second // OKX drops connections idle for 30 seconds
+	defaultStaleTimeout = 60 * time.Second // Reconnect if no message arrives within this time
+)
+
+// heartbeat sends OKX's plain text "ping" every ping interval until done is
+// closed, closing the connection when a ping is not answered
+func (c *Client) heartbeat(conn *wsconn.Conn, done <-chan struct{}) {
+	conn.Heartbeat(c.pingInterval, func() error {
+		return conn.WriteText([]byte("ping"))
+	}, done)
+}
@@ -0,0 +1,258 @@
+package okxconnector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+	"github.com/369geofreeman/inventory-control/real-time-system/reconnect"
+	"github.com/369geofreeman/inventory-control/real-time-system/wsconn"
+)
+
+const okxWSURL = "wss://ws.okx.com:8443/ws/v5/public"
+
+var _ marketdata.Source = (*Client)(nil)
+
+// Config defines what a Client subscribes to
+type Config struct {
+	InstIDs []string // Instruments to subscribe to, e.g. "BTC-USDT-SWAP"
+	URL     string   // WebSocket URL, defaults to OKX's public stream
+
+	PingInterval time.Duration // Interval between pings, defaults to 20s
+	StaleTimeout time.Duration // Reconnect when no message arrives within this time, defaults to 60s
+
+	Reconnect reconnect.Config
+}
+
+// SubscriptionError is returned when OKX answers a request with an error
+// event, which usually means a channel or instrument is misconfigured.
+// Reconnecting won't fix it, so Connect returns it instead of retrying.
+type SubscriptionError struct {
+	Code string // Error code given by OKX
+	Msg  string // Reason given by OKX
+}
+
+func (e *SubscriptionError) Error() string {
+	return fmt.Sprintf("subscription rejected with code %s: %s", e.Code, e.Msg)
+}
+
+// Client maintains a single WebSocket connection to OKX and publishes the
+// normalized messages for every subscribed instrument to its Feed.
+// Client implements marketdata.Source.
+type Client struct {
+	url           string
+	subscriptions map[string]*subscription
+	order         []string // Instruments in the order they were configured
+
+	pingInterval time.Duration
+	staleTimeout time.Duration
+	reconnect    reconnect.Config
+}
+
+// NewClient validates the config and creates a Feed for each instrument
+func NewClient(config Config) (*Client, error) {
+	if len(config.InstIDs) == 0 {
+		return nil, errors.New("at least one instrument is required")
+	}
+
+	url := config.URL
+	if url == "" {
+		url = okxWSURL
+	}
+
+	c := &Client{
+		url:           url,
+		subscriptions: make(map[string]*subscription, len(config.InstIDs)),
+		pingInterval:  config.PingInterval,
+		staleTimeout:  config.StaleTimeout,
+		reconnect:     config.Reconnect.WithDefaults(),
+	}
+	if c.pingInterval <= 0 {
+		c.pingInterval = defaultPingInterval
+	}
+	if c.staleTimeout <= 0 {
+		c.staleTimeout = defaultStaleTimeout
+	}
+	for _, instID := range config.InstIDs {
+		if _, ok := c.subscriptions[instID]; ok {
+			return nil, fmt.Errorf("duplicate instrument %s", instID)
+		}
+		c.subscriptions[instID] = newSubscription(instID)
+		c.order = append(c.order, instID)
+	}
+	return c, nil
+}
+
+// Venue returns the name of the venue
+func (c *Client) Venue() string {
+	return venue
+}
+
+// Feed returns the feed for the given instrument
+func (c *Client) Feed(instID string) (*marketdata.Feed, bool) {
+	sub, ok := c.subscriptions[instID]
+	if !ok {
+		return nil, false
+	}
+	return sub.feed, true
+}
+
+// Feeds returns every feed in the order the instruments were configured
+func (c *Client) Feeds() []*marketdata.Feed {
+	feeds := make([]*marketdata.Feed, 0, len(c.order))
+	for _, instID := range c.order {
+		feeds = append(feeds, c.subscriptions[instID].feed)
+	}
+	return feeds
+}
+
+// Connect connects to OKX and processes messages for every feed until ctx is
+// cancelled, it gives up or OKX rejects a request, in which case a *SubscriptionError is
+// returned. Failed connections are retried with a jittered exponential backoff.
+// Whenever a connection ends every feed is reset, so consumers see the market
+// data as not ready until the next session has rebuilt the books.
+func (c *Client) Connect(ctx context.Context) error {
+	return reconnect.Run(ctx, c.reconnect, c.connectAndListen, func(err error) bool {
+		c.resetFeeds()
+
+		var subErr *SubscriptionError
+		if errors.As(err, &subErr) {
+			log.Println("Error subscribing:", err)
+			return true
+		}
+		return false
+	})
+}
+
+// resetFeeds clears the state of every subscription after a disconnect
+func (c *Client) resetFeeds() {
+	for _, sub := range c.subscriptions {
+		sub.reset()
+	}
+}
+
+// connectAndListen runs a single connection until it fails. The connection
+// counts as established once every channel subscription was acknowledged.
+func (c *Client) connectAndListen(ctx context.Context) (bool, error) {
+	// Establish a WebSocket connection
+	conn, err := wsconn.Dial(ctx, c.url)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// Subscribe to the necessary channels, one request per instrument
+	expected := 0
+	for _, instID := range c.order {
+		args := c.subscriptions[instID].args()
+		if err := subscribe(conn, "subscribe", args...); err != nil {
+			return false, fmt.Errorf("failed to subscribe to channels for %s: %w", instID, err)
+		}
+		expected += len(args)
+	}
+
+	// Keep the connection alive, a missing pong closes it
+	done := make(chan struct{})
+	defer close(done)
+	go c.heartbeat(conn, done)
+
+	// Listen for incoming messages
+	acks := 0
+	for {
+		message, err := conn.Read(c.staleTimeout)
+		if err != nil {
+			if wsconn.IsTimeout(err) {
+				log.Printf("No message received within %s, reconnecting", c.staleTimeout)
+			} else {
+				log.Println("Error reading message:", err)
+			}
+			return acks >= expected, err
+		}
+		if string(message) == "pong" {
+			conn.Pong()
+			continue
+		}
+
+		sub, event, err := c.handleMessage(message)
+		if event == "subscribe" {
+			acks++
+		}
+		switch {
+		case errors.Is(err, ErrSequenceGap) || errors.Is(err, ErrChecksumMismatch):
+			// Resubscribing makes OKX send a fresh snapshot to rebuild the book from
+			log.Printf("%v, resubscribing to %s books", err, sub.instID)
+			if err := resync(conn, sub); err != nil {
+				return acks >= expected, err
+			}
+		case err != nil:
+			return acks >= expected, err
+		}
+	}
+}
+
+// subscribe sends a subscribe or unsubscribe request for the given channels
+func subscribe(conn *wsconn.Conn, op string, args ...Arg) error {
+	return conn.WriteJSON(map[string]interface{}{
+		"op":   op,
+		"args": args,
+	})
+}
+
+// resync resubscribes to an instrument's books channel so a new snapshot is sent
+func resync(conn *wsconn.Conn, sub *subscription) error {
+	books := Arg{Channel: "books", InstID: sub.instID}
+	if err := subscribe(conn, "unsubscribe", books); err != nil {
+		return err
+	}
+	return subscribe(conn, "subscribe", books)
+}
+
+// handleMessage routes a message to the subscription of its instrument and
+// returns it along with the event of the message, if any, and any error from
+// processing the message. An error event is returned as a *SubscriptionError.
+func (c *Client) handleMessage(message []byte) (*subscription, string, error) {
+	var msg Message
+
+	err := json.Unmarshal(message, &msg)
+	if err != nil {
+		log.Println("Error parsing message:", err)
+		return nil, "", nil
+	}
+
+	if msg.Event == "error" {
+		return nil, msg.Event, &SubscriptionError{Code: msg.Code, Msg: msg.Msg}
+	}
+
+	sub, ok := c.subscriptions[msg.Arg.InstID]
+	if !ok || msg.Event != "" {
+		return nil, msg.Event, nil
+	}
+
+	switch msg.Arg.Channel {
+	case "books":
+		return sub, "", sub.ProcessBook(msg.Action, msg.Data)
+
+	case "trades":
+		var trades []TradeData
+		err := json.Unmarshal(msg.Data, &trades)
+		if err != nil {
+			log.Println("Error parsing trades:", err)
+			return sub, "", nil
+		}
+		sub.ProcessTrades(trades)
+
+	case "tickers":
+		var tickers []TickerData
+		err := json.Unmarshal(msg.Data, &tickers)
+		if err != nil {
+			log.Println("Error parsing tickers:", err)
+			return sub, "", nil
+		}
+		sub.ProcessTickers(tickers)
+	}
+	return sub, "", nil
+}
@@ -0,0 +1,224 @@
+package okxconnector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/reconnect"
+	"github.com/gorilla/websocket"
+)
+
+// mockOKX is an in-process server speaking OKX's public protocol. It
+// acknowledges or rejects subscriptions and answers pings unless silent.
+type mockOKX struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu          sync.Mutex
+	connections int
+	pings       int
+	silent      bool   // Stop answering pings
+	reject      string // Error message sent in reply to subscriptions
+	ticker      bool   // Send a ticker after acknowledging subscriptions
+}
+
+func newMockOKX(t *testing.T) *mockOKX {
+	m := &mockOKX{t: t}
+	m.server = httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockOKX) client(t *testing.T, config Config) *Client {
+	config.InstIDs = []string{"BTC-USDT-SWAP"}
+	config.URL = "ws" + strings.TrimPrefix(m.server.URL, "http")
+	if config.Reconnect.InitialDelay == 0 {
+		config.Reconnect.InitialDelay = 10 * time.Millisecond
+	}
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func (m *mockOKX) serve(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		m.t.Error(err)
+		return
+	}
+	defer ws.Close()
+	m.mu.Lock()
+	m.connections++
+	m.mu.Unlock()
+
+	for {
+		_, message, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		m.mu.Lock()
+		silent, reject, ticker := m.silent, m.reject, m.ticker
+		if string(message) == "ping" {
+			m.pings++
+		}
+		m.mu.Unlock()
+
+		if string(message) == "ping" {
+			if !silent {
+				ws.WriteMessage(websocket.TextMessage, []byte("pong"))
+			}
+			continue
+		}
+
+		var req struct {
+			Op   string `json:"op"`
+			Args []Arg  `json:"args"`
+		}
+		if err := json.Unmarshal(message, &req); err != nil {
+			m.t.Errorf("Invalid request %s: %v", message, err)
+			return
+		}
+		if reject != "" {
+			ws.WriteMessage(websocket.TextMessage, []byte(`{"event":"error","code":"60018","msg":"`+reject+`","connId":"mock"}`))
+			continue
+		}
+		for _, arg := range req.Args {
+			data, _ := json.Marshal(map[string]interface{}{"event": req.Op, "arg": arg, "connId": "mock"})
+			ws.WriteMessage(websocket.TextMessage, data)
+		}
+		if ticker {
+			ws.WriteMessage(websocket.TextMessage, []byte(`{"arg":{"channel":"tickers","instId":"BTC-USDT-SWAP"},`+
+				`"data":[{"instId":"BTC-USDT-SWAP","last":"100","bidPx":"99.9","askPx":"100.1","ts":"1700000000000"}]}`))
+		}
+	}
+}
+
+func (m *mockOKX) counts() (connections, pings int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.connections, m.pings
+}
+
+// eventually polls until the condition holds or the test times out
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestKeepalive(t *testing.T) {
+	mock := newMockOKX(t)
+	client := mock.client(t, Config{PingInterval: 20 * time.Millisecond})
+	go client.Connect(context.Background())
+
+	// Answered pings keep a quiet connection open
+	eventually(t, func() bool {
+		_, pings := mock.counts()
+		return pings >= 5
+	})
+	if connections, _ := mock.counts(); connections != 1 {
+		t.Errorf("Expected a single connection, got %d", connections)
+	}
+}
+
+func TestMissedPongReconnects(t *testing.T) {
+	mock := newMockOKX(t)
+	mock.silent = true
+	client := mock.client(t, Config{PingInterval: 20 * time.Millisecond})
+	go client.Connect(context.Background())
+
+	eventually(t, func() bool {
+		connections, _ := mock.counts()
+		return connections >= 2
+	})
+}
+
+func TestFeedResetOnDisconnect(t *testing.T) {
+	mock := newMockOKX(t)
+	mock.silent = true
+	mock.ticker = true
+	client := mock.client(t, Config{
+		PingInterval: 100 * time.Millisecond,
+		Reconnect:    reconnect.Config{MaxAttempts: 1},
+	})
+
+	result := make(chan error, 1)
+	go func() { result <- client.Connect(context.Background()) }()
+	feed, _ := client.Feed("BTC-USDT-SWAP")
+	eventually(t, func() bool { return feed.Snapshot().LastPrice == 100 })
+
+	// The missed pong drops the connection and the state from before the gap
+	select {
+	case err := <-result:
+		if !errors.Is(err, reconnect.ErrGaveUp) {
+			t.Errorf("Expected ErrGaveUp, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the connection to be dropped")
+	}
+	if s := feed.Snapshot(); s.LastPrice != 0 || s.IsTickerReady {
+		t.Errorf("Expected the feed to be reset after the disconnect, got %+v", s)
+	}
+}
+
+func TestErrorEventIsReturned(t *testing.T) {
+	mock := newMockOKX(t)
+	mock.reject = "Wrong URL or channel:books,instId:BTC-USDT-SWAP doesn't exist."
+	client := mock.client(t, Config{})
+
+	result := make(chan error, 1)
+	go func() { result <- client.Connect(context.Background()) }()
+
+	select {
+	case err := <-result:
+		var subErr *SubscriptionError
+		if !errors.As(err, &subErr) {
+			t.Fatalf("Expected a SubscriptionError, got %v", err)
+		}
+		if subErr.Code != "60018" || subErr.Msg != mock.reject {
+			t.Errorf("Unexpected error %+v", subErr)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for Connect to return")
+	}
+	if connections, _ := mock.counts(); connections != 1 {
+		t.Errorf("Expected no reconnection after a rejection, got %d connections", connections)
+	}
+}
+
+func TestEstablishedSession(t *testing.T) {
+	mock := newMockOKX(t)
+	client := mock.client(t, Config{PingInterval: time.Hour, StaleTimeout: 50 * time.Millisecond})
+
+	// Every subscription is acknowledged before the connection goes stale
+	established, err := client.connectAndListen(context.Background())
+	if !established {
+		t.Error("Expected the session to be established")
+	}
+	if err == nil {
+		t.Error("Expected the stale connection to fail")
+	}
+
+	// Without acknowledgements the session only counts as a failure
+	mock.mu.Lock()
+	mock.reject = "rejected"
+	mock.mu.Unlock()
+	if established, _ := client.connectAndListen(context.Background()); established {
+		t.Error("Expected a rejected session not to be established")
+	}
+}
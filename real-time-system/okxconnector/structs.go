@@ -0,0 +1,57 @@
+package okxconnector
+
+import "encoding/json"
+
+// Arg identifies the channel and instrument of a subscription or push
+type Arg struct {
+	Channel string `json:"channel"`
+	InstID  string `json:"instId"`
+}
+
+// Message wraps every push and event received from OKX
+type Message struct {
+	Event  string          `json:"event"`  // "subscribe", "unsubscribe" or "error" for events
+	Code   string          `json:"code"`   // Error code for events
+	Msg    string          `json:"msg"`    // Error message for events
+	Arg    Arg             `json:"arg"`    // Channel the push belongs to
+	Action string          `json:"action"` // "snapshot" or "update" for books
+	Data   json.RawMessage `json:"data"`
+}
+
+// BookData represents the order book data within a books push. Each level is
+// [price, size, deprecated, number of orders].
+type BookData struct {
+	Asks      [][]string `json:"asks"`
+	Bids      [][]string `json:"bids"`
+	Timestamp string     `json:"ts"`
+	Checksum  int32      `json:"checksum"`
+	PrevSeqID int64      `json:"prevSeqId"` // -1 for snapshots
+	SeqID     int64      `json:"seqId"`
+}
+
+// TradeData represents a single trade within a trades push
+type TradeData struct {
+	InstID    string `json:"instId"`
+	TradeID   string `json:"tradeId"`
+	Price     string `json:"px"`
+	Size      string `json:"sz"`
+	Side      string `json:"side"` // Taker side, "buy" or "sell"
+	Timestamp string `json:"ts"`
+}
+
+// TickerData represents the ticker data within a tickers push
+type TickerData struct {
+	InstType  string `json:"instType"`
+	InstID    string `json:"instId"`
+	Last      string `json:"last"`
+	LastSize  string `json:"lastSz"`
+	AskPrice  string `json:"askPx"`
+	AskSize   string `json:"askSz"`
+	BidPrice  string `json:"bidPx"`
+	BidSize   string `json:"bidSz"`
+	Open24h   string `json:"open24h"`
+	High24h   string `json:"high24h"`
+	Low24h    string `json:"low24h"`
+	Vol24h    string `json:"vol24h"`
+	Timestamp string `json:"ts"`
+}
@@ -8,41 +8,6 @@ import (
 	"github.com/lanl/clp"
 )
 
-// Parameters for our optimization model
-var (
-	alpha = 0.05
-	beta  = 1.0
-	gamma = 1.0
-	delta = 0.5
-	zeta  = 0.1
-
-	// Variables to store the last successful bid and ask
-	lastSuccessfulBid float64
-	lastSuccessfulAsk float64
-
-	isEmaInitialized bool    = false
-	emaVolatility    float64 = 0
-	emaFactor        float64 = 0.1  // Defines the sensitivity of the EMA. Value between 0 and 1.
-	lowVolThreshold          = 0.5  // Example value, adjust based on observed volatility
-	highVolThreshold         = 2.0  // Example value, adjust based on observed volatility
-	minEmaFactor             = 0.05 // Minimum responsiveness
-	maxEmaFactor             = 0.5  // Maximum responsiveness
-)
-
-// SetParameters allows updating of optimization parameters dynamically
-func SetParameters(newAlpha, newBeta, newGamma, newDelta, newZeta float64) {
-	alpha = newAlpha
-	beta = newBeta
-	gamma = newGamma
-	delta = newDelta
-	zeta = newZeta
-}
-
-// GetParameters returns the current values of the optimization parameters
-func GetParameters() (float64, float64, float64, float64, float64) {
-	return alpha, beta, gamma, delta, zeta
-}
-
 // validateParameters checks if the provided parameters are within expected ranges.
 func validateParameters(currentPrice, maxInventory, volatility, liquidity, orderBookDepth float64) error {
 	if currentPrice <= 0 {
@@ -63,24 +28,31 @@ func validateParameters(currentPrice, maxInventory, volatility, liquidity, order
 	return nil
 }
 
-// OptimizeSpread calculates the optimal bid and ask prices based on market conditions
-func OptimizeSpread(currentPrice float64, inventory *Inventory, volatility, liquidity, orderBookDepth float64) (float64, float64, clp.SimplexStatus) {
+// OptimizeSpread calculates the optimal bid and ask prices based on market conditions.
+// volatility is annualized and should be a forecast over the quoting horizon where one is available.
+// Invalid parameters return the fallback spread with clp.StoppedDueToErrors.
+func (o *Optimizer) OptimizeSpread(currentPrice float64, inventory *Inventory, volatility, liquidity, orderBookDepth float64) (float64, float64, clp.SimplexStatus) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
 	// Fetch the current cash balance from the inventory
 	maxInventory, _ := inventory.GetBalances()
 	// Validate parameters before proceeding
 	if err := validateParameters(currentPrice, maxInventory, volatility, liquidity, orderBookDepth); err != nil {
 		log.Println("Warning:", err)
-		// Return a default spread around the current price as a fallback
-		defaultSpread := 0.5 // Default to a spread of 0.5, this can be adjusted
-		return currentPrice + defaultSpread, currentPrice - defaultSpread, 0
+		// Fall back to the last successful spread around the current price. The
+		// status must not read as clp.Optimal, which is 0, so callers can tell
+		// the spread was not optimized.
+		bid, ask := o.fallback(currentPrice)
+		return bid, ask, clp.StoppedDueToErrors
 	}
 
 	// Initialize EMA with the first volatility value received
-	if !isEmaInitialized {
-		emaVolatility = volatility
-		isEmaInitialized = true
+	if !o.state.IsEmaInitialized {
+		o.state.EmaVolatility = volatility
+		o.state.IsEmaInitialized = true
 	} else {
-		emaVolatility = (1-emaFactor)*emaVolatility + emaFactor*volatility
+		o.state.EmaVolatility = (1-o.state.EmaFactor)*o.state.EmaVolatility + o.state.EmaFactor*volatility
 	}
 
 	// Using CLP to solve the LP problem
@@ -106,11 +78,10 @@ func OptimizeSpread(currentPrice float64, inventory *Inventory, volatility, liqu
 	// initialAsk := currentPrice + askDeviation
 
 	// Dynamic weights to prioritize alignment with current price
-	c := objectiveFunction(currentPrice, volatility, liquidity, orderBookDepth, assetRatio)
-
+	c := o.params.objectiveFunction(currentPrice, volatility, liquidity, orderBookDepth, assetRatio)
 
 	// Define variable bounds
-	minSpread := currentPrice * 0.01                   // 0.01 Minimum allowable spread between bid and ask
+	minSpread := currentPrice * 0.01 // 0.01 Minimum allowable spread between bid and ask
 	varBounds := [][2]float64{
 		{currentPrice - bidDeviation, currentPrice}, // Bounds for Bid Price
 		{currentPrice, currentPrice + askDeviation}, // Bounds for Ask Price
@@ -120,7 +91,7 @@ func OptimizeSpread(currentPrice float64, inventory *Inventory, volatility, liqu
 	ineqs := [][]float64{
 		{0, 1, -1, -minSpread}, // Ensure bid is less than ask by at least minSpread
 	}
-	
+
 	simp.EasyLoadDenseProblem(c, varBounds, ineqs)
 	simp.SetOptimizationDirection(clp.Minimize)
 	test_primal := simp.Primal(clp.NoValuesPass, clp.NoStartFinishOptions)
@@ -149,11 +120,32 @@ func OptimizeSpread(currentPrice float64, inventory *Inventory, volatility, liqu
 	log.Println("optimalAsk", optimalAsk)
 	log.Println("optX", optX)
 
+	if test_primal == clp.Optimal {
+		o.state.LastSuccessfulBid = optimalBid
+		o.state.LastSuccessfulAsk = optimalAsk
+		o.state.LastSuccessfulPrice = currentPrice
+	}
 	return optimalBid, optimalAsk, test_primal
 }
 
-func GetOptimizationFrequency() int {
-	if emaVolatility > 1.5 { // Thresholds can be adjusted based on your needs
+// fallback returns the last successful spread recentred on the current
+// price, or the default spread if no optimization has succeeded yet
+func (o *Optimizer) fallback(currentPrice float64) (float64, float64) {
+	if o.state.LastSuccessfulPrice > 0 {
+		return currentPrice - (o.state.LastSuccessfulPrice - o.state.LastSuccessfulBid),
+			currentPrice + (o.state.LastSuccessfulAsk - o.state.LastSuccessfulPrice)
+	}
+	return currentPrice - o.params.DefaultSpread, currentPrice + o.params.DefaultSpread
+}
+
+// GetOptimizationFrequency returns how often, in minutes, to optimize given
+// the smoothed volatility
+func (o *Optimizer) GetOptimizationFrequency() int {
+	o.mu.Lock()
+	emaVolatility := o.state.EmaVolatility
+	o.mu.Unlock()
+
+	if emaVolatility > 1.5 { // Thresholds are annualized volatility, i.e. 150% and 100%
 		return 1 // Optimize every minute
 	} else if emaVolatility > 1.0 {
 		return 5 // Optimize every 5 minutes
@@ -178,10 +170,10 @@ func max(a, b float64) float64 {
 }
 
 // Objective function to define the coefficients for the optimization problem
-func objectiveFunction(currentPrice, volatility, liquidity, orderBookDepth float64, assetRatio float64) []float64 {
-	volatilityPenalty := alpha * volatility
-	liquidityPenalty := beta / (liquidity + 1) // Assuming liquidity is always positive
-	orderBookPenalty := gamma * math.Log(1 + orderBookDepth)
+func (p Params) objectiveFunction(currentPrice, volatility, liquidity, orderBookDepth float64, assetRatio float64) []float64 {
+	volatilityPenalty := p.Alpha * volatility
+	liquidityPenalty := p.Beta / (liquidity + 1) // Assuming liquidity is always positive
+	orderBookPenalty := p.Gamma * math.Log(1+orderBookDepth)
 
 	return []float64{
 		-1 + volatilityPenalty + liquidityPenalty + orderBookPenalty,
@@ -189,26 +181,31 @@ func objectiveFunction(currentPrice, volatility, liquidity, orderBookDepth float
 	}
 }
 
-
-func costFunction(vA, vB, a, b, currentPrice, volatility float64) float64 {
-	inventoryRisk := alpha * math.Pow(vA-vB, 2) // Penalize imbalances between vA and vB
-	priceRisk := beta * (math.Pow(a-currentPrice, 2) + math.Pow(b-currentPrice, 2))
+func (p Params) costFunction(vA, vB, a, b, currentPrice, volatility float64) float64 {
+	inventoryRisk := p.Alpha * math.Pow(vA-vB, 2) // Penalize imbalances between vA and vB
+	priceRisk := p.Beta * (math.Pow(a-currentPrice, 2) + math.Pow(b-currentPrice, 2))
 	return inventoryRisk + priceRisk
 }
 
-func baseSpreadFunction(volatility, liquidity, orderBookDepth float64) float64 {
+func (p Params) baseSpreadFunction(volatility, liquidity, orderBookDepth float64) float64 {
 	// Adjusted the coefficients to make the spread more sensitive to market conditions
-	return 2*gamma*volatility + delta/(liquidity+1) + 2*zeta*math.Log(1+orderBookDepth)
+	return 2*p.Gamma*volatility + p.Delta/(liquidity+1) + 2*p.Zeta*math.Log(1+orderBookDepth)
 }
 
-func AdjustEmaFactorBasedOnVolatility(volatility float64) {
-	if volatility <= lowVolThreshold {
-		emaFactor = minEmaFactor
-	} else if volatility >= highVolThreshold {
-		emaFactor = maxEmaFactor
+// AdjustEmaFactorBasedOnVolatility makes the volatility EMA more responsive
+// as annualized volatility rises
+func (o *Optimizer) AdjustEmaFactorBasedOnVolatility(volatility float64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	p := o.params
+	if volatility <= p.LowVolThreshold {
+		o.state.EmaFactor = p.MinEmaFactor
+	} else if volatility >= p.HighVolThreshold {
+		o.state.EmaFactor = p.MaxEmaFactor
 	} else {
 		// Linear scaling between the thresholds
-		scale := (volatility - lowVolThreshold) / (highVolThreshold - lowVolThreshold)
-		emaFactor = minEmaFactor + scale*(maxEmaFactor-minEmaFactor)
+		scale := (volatility - p.LowVolThreshold) / (p.HighVolThreshold - p.LowVolThreshold)
+		o.state.EmaFactor = p.MinEmaFactor + scale*(p.MaxEmaFactor-p.MinEmaFactor)
 	}
 }
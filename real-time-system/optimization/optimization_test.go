@@ -108,7 +108,11 @@ var testCases = []struct {
 func TestOptimizeSpread(t *testing.T) {
 	for _, tt := range testCases {
 		t.Run("", func(t *testing.T) {
-			optimalBid, optimalAsk, primalStatus := OptimizeSpread(
+			optimizer, err := NewOptimizer(DefaultParams())
+			if err != nil {
+				t.Fatal(err)
+			}
+			optimalBid, optimalAsk, primalStatus := optimizer.OptimizeSpread(
 				tt.currentPrice,
 				tt.inventory,
 				tt.volatility,
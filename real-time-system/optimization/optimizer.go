@@ -0,0 +1,138 @@
+package optimization
+
+import (
+	"errors"
+	"sync"
+)
+
+// Params are the coefficients of the optimization model
+type Params struct {
+	Alpha float64 `json:"alpha"` // Weight of volatility in the objective and inventory risk
+	Beta  float64 `json:"beta"`  // Weight of illiquidity in the objective and price risk
+	Gamma float64 `json:"gamma"` // Weight of order book depth in the objective and volatility in the base spread
+	Delta float64 `json:"delta"` // Weight of illiquidity in the base spread
+	Zeta  float64 `json:"zeta"`  // Weight of order book depth in the base spread
+
+	// The EMA factor scales linearly from MinEmaFactor to MaxEmaFactor as
+	// annualized volatility rises from LowVolThreshold to HighVolThreshold
+	EmaFactor        float64 `json:"ema_factor"` // Initial sensitivity of the EMA, between 0 and 1
+	LowVolThreshold  float64 `json:"low_vol_threshold"`
+	HighVolThreshold float64 `json:"high_vol_threshold"`
+	MinEmaFactor     float64 `json:"min_ema_factor"` // Minimum responsiveness
+	MaxEmaFactor     float64 `json:"max_ema_factor"` // Maximum responsiveness
+
+	// Spread quoted around the price when the inputs are invalid and no
+	// optimization has succeeded yet
+	DefaultSpread float64 `json:"default_spread"`
+}
+
+// DefaultParams returns the parameters the model was calibrated with
+func DefaultParams() Params {
+	return Params{
+		Alpha:            0.05,
+		Beta:             1.0,
+		Gamma:            1.0,
+		Delta:            0.5,
+		Zeta:             0.1,
+		EmaFactor:        0.1,
+		LowVolThreshold:  0.5, // Annualized volatility, i.e. 50%
+		HighVolThreshold: 2.0, // Annualized volatility, i.e. 200%
+		MinEmaFactor:     0.05,
+		MaxEmaFactor:     0.5,
+		DefaultSpread:    0.5,
+	}
+}
+
+// Validate checks that the parameters are within expected ranges
+func (p Params) Validate() error {
+	if p.Alpha < 0 || p.Beta < 0 || p.Gamma < 0 || p.Delta < 0 || p.Zeta < 0 {
+		return errors.New("alpha, beta, gamma, delta and zeta should not be negative")
+	}
+	for _, factor := range []float64{p.EmaFactor, p.MinEmaFactor, p.MaxEmaFactor} {
+		if factor <= 0 || factor > 1 {
+			return errors.New("EMA factors should be greater than 0 and at most 1")
+		}
+	}
+	if p.MinEmaFactor > p.MaxEmaFactor {
+		return errors.New("minEmaFactor should not be greater than maxEmaFactor")
+	}
+	if p.LowVolThreshold < 0 || p.HighVolThreshold <= p.LowVolThreshold {
+		return errors.New("volatility thresholds should satisfy 0 <= low < high")
+	}
+	if p.DefaultSpread <= 0 {
+		return errors.New("defaultSpread should be greater than 0")
+	}
+	return nil
+}
+
+// State is everything an Optimizer learns while running. It can be saved,
+// e.g. as JSON, and restored after a restart.
+type State struct {
+	// The last successful bid and ask and the price they were quoted around
+	LastSuccessfulBid   float64 `json:"last_successful_bid"`
+	LastSuccessfulAsk   float64 `json:"last_successful_ask"`
+	LastSuccessfulPrice float64 `json:"last_successful_price"`
+
+	IsEmaInitialized bool    `json:"is_ema_initialized"`
+	EmaVolatility    float64 `json:"ema_volatility"`
+	EmaFactor        float64 `json:"ema_factor"`
+}
+
+// Optimizer calculates optimal bid and ask prices for a single symbol.
+// Optimizer is safe for concurrent use.
+type Optimizer struct {
+	mu     sync.Mutex
+	params Params
+	state  State
+}
+
+// NewOptimizer creates an optimizer with the given parameters
+func NewOptimizer(params Params) (*Optimizer, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	return &Optimizer{
+		params: params,
+		state:  State{EmaFactor: params.EmaFactor},
+	}, nil
+}
+
+// SetParameters allows updating of optimization parameters dynamically.
+// The learned state is kept.
+func (o *Optimizer) SetParameters(params Params) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.params = params
+	return nil
+}
+
+// Parameters returns the current values of the optimization parameters
+func (o *Optimizer) Parameters() Params {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.params
+}
+
+// State returns a copy of the learned state
+func (o *Optimizer) State() State {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.state
+}
+
+// Restore replaces the learned state, e.g. with one saved before a restart
+func (o *Optimizer) Restore(state State) error {
+	if state.EmaVolatility < 0 || state.EmaFactor <= 0 || state.EmaFactor > 1 {
+		return errors.New("state has an invalid EMA")
+	}
+	if state.LastSuccessfulPrice < 0 || state.LastSuccessfulBid > state.LastSuccessfulAsk {
+		return errors.New("state has an invalid last successful spread")
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.state = state
+	return nil
+}
@@ -0,0 +1,144 @@
+package optimization
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/lanl/clp"
+)
+
+func TestParamsValidate(t *testing.T) {
+	if err := DefaultParams().Validate(); err != nil {
+		t.Fatalf("Expected the default parameters to be valid, got %v", err)
+	}
+
+	invalid := []func(*Params){
+		func(p *Params) { p.Alpha = -1 },
+		func(p *Params) { p.EmaFactor = 0 },
+		func(p *Params) { p.MaxEmaFactor = 1.5 },
+		func(p *Params) { p.MinEmaFactor, p.MaxEmaFactor = 0.5, 0.1 },
+		func(p *Params) { p.HighVolThreshold = p.LowVolThreshold },
+		func(p *Params) { p.DefaultSpread = 0 },
+	}
+	for i, modify := range invalid {
+		params := DefaultParams()
+		modify(&params)
+		if _, err := NewOptimizer(params); err == nil {
+			t.Errorf("Expected case %d to be rejected", i)
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	optimizer, err := NewOptimizer(DefaultParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a successful optimization the default spread is used
+	bid, ask, status := optimizer.OptimizeSpread(100, &Inventory{1000, 1, 0}, 0.5, 0, 1)
+	if bid != 99.5 || ask != 100.5 {
+		t.Errorf("Expected the default spread 99.5/100.5, got %f/%f", bid, ask)
+	}
+	if status == clp.Optimal {
+		t.Error("Expected the fallback not to be reported as optimal")
+	}
+
+	// Otherwise the last successful spread is recentred on the price
+	err = optimizer.Restore(State{
+		LastSuccessfulBid:   98,
+		LastSuccessfulAsk:   101,
+		LastSuccessfulPrice: 100,
+		EmaFactor:           0.1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bid, ask, _ = optimizer.OptimizeSpread(200, &Inventory{1000, 1, 0}, 0.5, 0, 1)
+	if bid != 198 || ask != 201 {
+		t.Errorf("Expected the last spread 198/201, got %f/%f", bid, ask)
+	}
+}
+
+func TestAdjustEmaFactor(t *testing.T) {
+	optimizer, err := NewOptimizer(DefaultParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		volatility float64
+		expected   float64
+	}{
+		{0.1, 0.05},
+		{0.5, 0.05},
+		{1.25, 0.275},
+		{3, 0.5},
+	}
+	for _, tt := range tests {
+		optimizer.AdjustEmaFactorBasedOnVolatility(tt.volatility)
+		if factor := optimizer.State().EmaFactor; factor != tt.expected {
+			t.Errorf("Expected EMA factor %f at volatility %f, got %f", tt.expected, tt.volatility, factor)
+		}
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	optimizer, err := NewOptimizer(DefaultParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := State{
+		LastSuccessfulBid:   99,
+		LastSuccessfulAsk:   101,
+		LastSuccessfulPrice: 100,
+		IsEmaInitialized:    true,
+		EmaVolatility:       1.2,
+		EmaFactor:           0.3,
+	}
+	if err := optimizer.Restore(state); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(optimizer.State())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored State
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatal(err)
+	}
+	if restored != state {
+		t.Errorf("Expected %+v after a round trip, got %+v", state, restored)
+	}
+	if frequency := optimizer.GetOptimizationFrequency(); frequency != 5 {
+		t.Errorf("Expected an optimization every 5 minutes, got %d", frequency)
+	}
+
+	if err := optimizer.Restore(State{LastSuccessfulBid: 101, LastSuccessfulAsk: 99, EmaFactor: 0.1}); err == nil {
+		t.Error("Expected a crossed spread to be rejected")
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	optimizer, err := NewOptimizer(DefaultParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				optimizer.AdjustEmaFactorBasedOnVolatility(float64(j) / 10)
+				optimizer.OptimizeSpread(100, &Inventory{1000, 1, 0}, 0.5, 0, 1)
+				optimizer.State()
+				optimizer.GetOptimizationFrequency()
+			}
+		}()
+	}
+	wg.Wait()
+}
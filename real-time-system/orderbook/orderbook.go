@@ -0,0 +1,186 @@
+package orderbook
+
+import "sort"
+
+// Side identifies the bid or ask side of the book
+type Side int
+
+const (
+	Bid Side = iota
+	Ask
+)
+
+// Level represents the aggregated size resting at a single price
+type Level struct {
+	Price float64
+	Size  float64
+}
+
+// Book is a local L2 order book maintained from snapshot and delta updates.
+// Bids are kept sorted from highest to lowest price and asks from lowest to highest.
+// A Book is not safe for concurrent use; callers are expected to hold their own lock.
+type Book struct {
+	bids []Level
+	asks []Level
+}
+
+// New creates an empty order book
+func New() *Book {
+	return &Book{}
+}
+
+// Reset removes every level from the book
+func (b *Book) Reset() {
+	b.bids = b.bids[:0]
+	b.asks = b.asks[:0]
+}
+
+// ApplySnapshot replaces the contents of the book with the given levels
+func (b *Book) ApplySnapshot(bids, asks []Level) {
+	b.Reset()
+	b.ApplyDelta(bids, asks)
+}
+
+// ApplyDelta updates the book with the given levels. A level with a size of
+// zero removes the price from the book, any other size replaces it.
+func (b *Book) ApplyDelta(bids, asks []Level) {
+	for _, level := range bids {
+		b.bids = update(b.bids, level, Bid)
+	}
+	for _, level := range asks {
+		b.asks = update(b.asks, level, Ask)
+	}
+}
+
+// BestBid returns the highest bid, or false if there are no bids
+func (b *Book) BestBid() (Level, bool) {
+	if len(b.bids) == 0 {
+		return Level{}, false
+	}
+	return b.bids[0], true
+}
+
+// BestAsk returns the lowest ask, or false if there are no asks
+func (b *Book) BestAsk() (Level, bool) {
+	if len(b.asks) == 0 {
+		return Level{}, false
+	}
+	return b.asks[0], true
+}
+
+// MidPrice returns the price halfway between the best bid and best ask,
+// or false if either side of the book is empty
+func (b *Book) MidPrice() (float64, bool) {
+	bid, okBid := b.BestBid()
+	ask, okAsk := b.BestAsk()
+	if !okBid || !okAsk {
+		return 0, false
+	}
+	return (bid.Price + ask.Price) / 2, true
+}
+
+// SizeAt returns the size resting at exactly the given price
+func (b *Book) SizeAt(side Side, price float64) float64 {
+	levels := b.side(side)
+	i := search(levels, price, side)
+	if i < len(levels) && levels[i].Price == price {
+		return levels[i].Size
+	}
+	return 0
+}
+
+// CumulativeVolume returns the total size of every level priced at or better
+// than limit, i.e. bids at or above limit and asks at or below it
+func (b *Book) CumulativeVolume(side Side, limit float64) float64 {
+	var total float64
+	for _, level := range b.side(side)[:b.LevelsWithin(side, limit)] {
+		total += level.Size
+	}
+	return total
+}
+
+// LevelsWithin returns the number of levels priced at or better than limit
+func (b *Book) LevelsWithin(side Side, limit float64) int {
+	levels := b.side(side)
+	return sort.Search(len(levels), func(i int) bool {
+		if side == Bid {
+			return levels[i].Price < limit
+		}
+		return levels[i].Price > limit
+	})
+}
+
+// Levels returns a copy of up to n levels from one side of the book, best
+// price first. A non-positive n returns every level.
+func (b *Book) Levels(side Side, n int) []Level {
+	levels := b.side(side)
+	if n <= 0 || n > len(levels) {
+		n = len(levels)
+	}
+	out := make([]Level, n)
+	copy(out, levels[:n])
+	return out
+}
+
+// At returns the i-th level from one side of the book, best price first.
+// It panics if i is out of range.
+func (b *Book) At(side Side, i int) Level {
+	return b.side(side)[i]
+}
+
+// Volume returns the total size of up to n levels from one side of the
+// book, best price first. A non-positive n sums every level.
+func (b *Book) Volume(side Side, n int) float64 {
+	levels := b.side(side)
+	if n <= 0 || n > len(levels) {
+		n = len(levels)
+	}
+	var total float64
+	for _, level := range levels[:n] {
+		total += level.Size
+	}
+	return total
+}
+
+// Len returns the number of levels on one side of the book
+func (b *Book) Len(side Side) int {
+	return len(b.side(side))
+}
+
+func (b *Book) side(side Side) []Level {
+	if side == Bid {
+		return b.bids
+	}
+	return b.asks
+}
+
+// search returns the index of price in levels, or where it would be inserted
+func search(levels []Level, price float64, side Side) int {
+	return sort.Search(len(levels), func(i int) bool {
+		if side == Bid {
+			return levels[i].Price <= price
+		}
+		return levels[i].Price >= price
+	})
+}
+
+// update inserts, replaces or removes a single level keeping the slice sorted
+func update(levels []Level, level Level, side Side) []Level {
+	i := search(levels, level.Price, side)
+	found := i < len(levels) && levels[i].Price == level.Price
+
+	switch {
+	case level.Size <= 0 && found:
+		return append(levels[:i], levels[i+1:]...)
+	case level.Size <= 0:
+		return levels
+	case found:
+		levels[i].Size = level.Size
+		return levels
+	}
+
+	levels = append(levels, Level{})
+	copy(levels[i+1:], levels[i:])
+	levels[i] = level
+	return levels
+}
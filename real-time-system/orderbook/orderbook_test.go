@@ -0,0 +1,93 @@
+package orderbook
+
+import "testing"
+
+func TestApplySnapshotAndDelta(t *testing.T) {
+	book := New()
+	book.ApplySnapshot(
+		[]Level{{100, 1}, {102, 2}, {101, 3}},
+		[]Level{{104, 1}, {103, 2}, {105, 0}},
+	)
+
+	if bid, _ := book.BestBid(); bid.Price != 102 {
+		t.Errorf("Expected best bid 102, got %f", bid.Price)
+	}
+	if ask, _ := book.BestAsk(); ask.Price != 103 {
+		t.Errorf("Expected best ask 103, got %f", ask.Price)
+	}
+	if n := book.Len(Ask); n != 2 {
+		t.Errorf("Expected zero-size snapshot level to be skipped, got %d asks", n)
+	}
+
+	// Remove the best bid, resize a level and add a new one
+	book.ApplyDelta(
+		[]Level{{102, 0}, {100, 5}, {99, 1}},
+		[]Level{{103.5, 4}},
+	)
+
+	expectedBids := []Level{{101, 3}, {100, 5}, {99, 1}}
+	bids := book.Levels(Bid, 0)
+	if len(bids) != len(expectedBids) {
+		t.Fatalf("Expected %d bids, got %d", len(expectedBids), len(bids))
+	}
+	for i, level := range expectedBids {
+		if bids[i] != level {
+			t.Errorf("Expected bid %d to be %v, got %v", i, level, bids[i])
+		}
+	}
+
+	if ask, _ := book.BestAsk(); ask.Price != 103 {
+		t.Errorf("Expected best ask 103, got %f", ask.Price)
+	}
+	if mid, _ := book.MidPrice(); mid != 102 {
+		t.Errorf("Expected mid price 102, got %f", mid)
+	}
+
+	// A removal for a price that is not in the book is a no-op
+	book.ApplyDelta([]Level{{50, 0}}, nil)
+	if n := book.Len(Bid); n != 3 {
+		t.Errorf("Expected 3 bids, got %d", n)
+	}
+
+	// A new snapshot replaces everything
+	book.ApplySnapshot([]Level{{90, 1}}, nil)
+	if _, ok := book.MidPrice(); ok {
+		t.Error("Expected no mid price with an empty ask side")
+	}
+}
+
+func TestQueries(t *testing.T) {
+	book := New()
+	book.ApplySnapshot(
+		[]Level{{100, 1}, {99, 2}, {98, 3}},
+		[]Level{{101, 1}, {102, 2}, {103, 3}},
+	)
+
+	if size := book.SizeAt(Bid, 99); size != 2 {
+		t.Errorf("Expected size 2 at 99, got %f", size)
+	}
+	if size := book.SizeAt(Ask, 101.5); size != 0 {
+		t.Errorf("Expected size 0 at 101.5, got %f", size)
+	}
+	if volume := book.CumulativeVolume(Bid, 99); volume != 3 {
+		t.Errorf("Expected bid volume 3 down to 99, got %f", volume)
+	}
+	if volume := book.CumulativeVolume(Ask, 102.5); volume != 3 {
+		t.Errorf("Expected ask volume 3 up to 102.5, got %f", volume)
+	}
+	if n := book.LevelsWithin(Ask, 110); n != 3 {
+		t.Errorf("Expected 3 ask levels up to 110, got %d", n)
+	}
+	if n := book.LevelsWithin(Bid, 100.5); n != 0 {
+		t.Errorf("Expected 0 bid levels down to 100.5, got %d", n)
+	}
+	if volume := book.Volume(Bid, 2); volume != 3 {
+		t.Errorf("Expected volume 3 in the top 2 bid levels, got %f", volume)
+	}
+	if level := book.At(Ask, 1); level != (Level{102, 2}) {
+		t.Errorf("Expected the second ask level to be 102, got %v", level)
+	}
+	if volume := book.Volume(Ask, 0); volume != 6 {
+		t.Errorf("Expected volume 6 in every ask level, got %f", volume)
+	}
+}
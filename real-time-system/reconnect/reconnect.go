@@ -0,0 +1,105 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultInitialDelay   = 1 * time.Second
+	defaultMaxDelay       = 60 * time.Second
+	defaultHealthySession = 60 * time.Second
+	backoffFactor         = 2 // Multiplier for each subsequent reconnection delay
+)
+
+// ErrGaveUp is returned by Run once MaxAttempts consecutive sessions have failed
+var ErrGaveUp = errors.New("max reconnection attempts reached")
+
+// Config controls how a venue connection is re-established after it fails
+type Config struct {
+	InitialDelay time.Duration // Delay before the first reconnection, defaults to 1s
+	MaxDelay     time.Duration // Cap on the exponential backoff, defaults to 60s
+	// A session that was established and lasted at least this long resets
+	// the backoff, defaults to 60s
+	HealthySession time.Duration
+	// Consecutive failed sessions before Run gives up and returns
+	// ErrGaveUp. Zero means never give up.
+	MaxAttempts int
+}
+
+// WithDefaults returns the config with unset fields replaced by defaults
+func (c Config) WithDefaults() Config {
+	if c.InitialDelay <= 0 {
+		c.InitialDelay = defaultInitialDelay
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = defaultMaxDelay
+	}
+	if c.HealthySession <= 0 {
+		c.HealthySession = defaultHealthySession
+	}
+	return c
+}
+
+// Backoff returns the delay before the given reconnection attempt, starting
+// at 1. The delay doubles with every attempt up to MaxDelay and is jittered
+// between half and the full value so clients don't reconnect in lockstep.
+func (c Config) Backoff(attempt int) time.Duration {
+	delay := c.InitialDelay
+	for i := 1; i < attempt && delay < c.MaxDelay; i++ {
+		delay *= backoffFactor
+	}
+	if delay > c.MaxDelay {
+		delay = c.MaxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// Run runs sessions one after another with a jittered exponential backoff in
+// between until ctx is cancelled. session runs a single connection until it
+// ends or ctx is cancelled and reports whether it was established, e.g.
+// every subscription was acknowledged. ended is called after every session,
+// e.g. to reset feeds, and returns whether its error is fatal, e.g. a
+// rejected subscription that reconnecting won't fix, in which case Run
+// returns it. Otherwise Run returns ctx's error once it is cancelled, or
+// ErrGaveUp once MaxAttempts consecutive sessions have failed.
+func Run(ctx context.Context, config Config, session func(ctx context.Context) (established bool, err error), ended func(err error) (fatal bool)) error {
+	config = config.WithDefaults()
+	failures := 0
+	for {
+		started := time.Now()
+		established, err := session(ctx)
+		if ended(err) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// A healthy session means the problem was transient, start over
+		if established && time.Since(started) >= config.HealthySession {
+			failures = 0
+		} else {
+			failures++
+		}
+
+		log.Printf("Error connecting or listening: %v", err)
+		if config.MaxAttempts > 0 && failures >= config.MaxAttempts {
+			log.Println("Max reconnection attempts reached, giving up")
+			return ErrGaveUp
+		}
+
+		delay := config.Backoff(failures + 1)
+		log.Printf("Attempt %d. Reconnecting in %s...", failures+1, delay.Round(time.Millisecond))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
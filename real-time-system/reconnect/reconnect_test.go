@@ -0,0 +1,97 @@
+package reconnect
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	config := Config{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second}.WithDefaults()
+	for attempt, full := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		4:  800 * time.Millisecond,
+		10: time.Second,
+	} {
+		for i := 0; i < 20; i++ {
+			if delay := config.Backoff(attempt); delay < full/2 || delay > full {
+				t.Errorf("Expected attempt %d to wait between %s and %s, got %s", attempt, full/2, full, delay)
+			}
+		}
+	}
+}
+
+func TestRunGivesUpAfterMaxAttempts(t *testing.T) {
+	sessions := 0
+	err := Run(context.Background(), Config{InitialDelay: time.Millisecond, MaxAttempts: 3},
+		func(context.Context) (bool, error) {
+			sessions++
+			return false, errors.New("dial failed")
+		},
+		func(error) bool { return false },
+	)
+	if !errors.Is(err, ErrGaveUp) {
+		t.Fatalf("Expected ErrGaveUp, got %v", err)
+	}
+	if sessions != 3 {
+		t.Errorf("Expected exactly 3 sessions, got %d", sessions)
+	}
+}
+
+func TestRunResetsAfterHealthySession(t *testing.T) {
+	// Sessions alternate between healthy and failed, so failures never add up
+	sessions := 0
+	fatal := errors.New("fatal")
+	err := Run(context.Background(), Config{InitialDelay: time.Millisecond, HealthySession: 5 * time.Millisecond, MaxAttempts: 2},
+		func(context.Context) (bool, error) {
+			sessions++
+			if sessions == 10 {
+				return false, fatal
+			}
+			if sessions%2 == 1 {
+				time.Sleep(5 * time.Millisecond)
+				return true, errors.New("closed by the venue")
+			}
+			return false, errors.New("dial failed")
+		},
+		func(err error) bool { return errors.Is(err, fatal) },
+	)
+	if !errors.Is(err, fatal) {
+		t.Fatalf("Expected the fatal error, got %v", err)
+	}
+	if sessions != 10 {
+		t.Errorf("Expected 10 sessions, got %d", sessions)
+	}
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sessions := 0
+	result := make(chan error, 1)
+	go func() {
+		result <- Run(ctx, Config{InitialDelay: time.Hour},
+			func(context.Context) (bool, error) {
+				sessions++
+				return false, errors.New("dial failed")
+			},
+			func(error) bool { return false },
+		)
+	}()
+
+	// Cancelling interrupts the backoff instead of waiting for the next attempt
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for Run to return")
+	}
+	if sessions != 1 {
+		t.Errorf("Expected a single session, got %d", sessions)
+	}
+}
@@ -0,0 +1,226 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fileTimeLayout = "20060102-15" // One file per UTC hour
+	fileExtension  = ".jsonl.gz"
+	flushInterval  = time.Second // Maximum time a frame stays in memory before it is flushed
+)
+
+// Record is a single line of a recording
+type Record struct {
+	ReceivedAt int64           `json:"recv_ns"` // Local receive time in nanoseconds since the epoch
+	Topic      string          `json:"topic"`
+	Message    json.RawMessage `json:"msg"` // Raw frame, or a JSON string if the frame was not valid JSON
+}
+
+// Recorder writes raw WebSocket frames to gzip compressed JSON lines files,
+// one file per topic per hour under dir/<topic>/<YYYYMMDD-HH>.jsonl.gz.
+// Buffered frames are flushed in the background every flushInterval, so a
+// quiet topic doesn't hold its last frames in memory.
+// Recorder is safe for concurrent use.
+type Recorder struct {
+	dir string
+
+	mu     sync.Mutex
+	files  map[string]*file // Open file by topic
+	closed bool
+
+	done chan struct{} // Closed to stop the background flush
+}
+
+// file is the currently open file of a single topic
+type file struct {
+	hour  time.Time
+	f     *os.File
+	gz    *gzip.Writer
+	buf   *bufio.Writer
+	dirty bool // Lines were written since the last flush
+}
+
+// New creates a recorder writing under dir, creating it if necessary
+func New(dir string) (*Recorder, error) {
+	return newRecorder(dir, flushInterval)
+}
+
+func newRecorder(dir string, interval time.Duration) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	r := &Recorder{dir: dir, files: make(map[string]*file), done: make(chan struct{})}
+	go r.flushEvery(interval)
+	return r, nil
+}
+
+// flushEvery flushes every open file until the recorder is closed, and closes
+// files whose hour has passed so they are complete on disk without waiting
+// for the next frame of their topic
+func (r *Recorder) flushEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case now := <-ticker.C:
+			if err := r.flush(now.UTC().Truncate(time.Hour)); err != nil {
+				log.Println("Error flushing recording:", err)
+			}
+		}
+	}
+}
+
+// flush flushes every file with buffered lines and closes the files of hours
+// before the given one
+func (r *Recorder) flush(hour time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var firstErr error
+	for topic, f := range r.files {
+		var err error
+		if f.hour.Before(hour) {
+			err = f.close()
+			delete(r.files, topic)
+		} else if f.dirty {
+			err = f.flush()
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", topic, err)
+		}
+	}
+	return firstErr
+}
+
+// Record appends a frame to the file of its topic, rotating the file when
+// the receive time moves into a new hour
+func (r *Recorder) Record(topic string, receivedAt time.Time, message []byte) error {
+	if topic == "" {
+		topic = "other"
+	}
+
+	raw := json.RawMessage(message)
+	if !json.Valid(message) {
+		quoted, err := json.Marshal(string(message))
+		if err != nil {
+			return err
+		}
+		raw = quoted
+	}
+	line, err := json.Marshal(Record{ReceivedAt: receivedAt.UnixNano(), Topic: topic, Message: raw})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return errors.New("recorder is closed")
+	}
+
+	hour := receivedAt.UTC().Truncate(time.Hour)
+	f, ok := r.files[topic]
+	if !ok || !f.hour.Equal(hour) {
+		if ok {
+			if err := f.close(); err != nil {
+				return err
+			}
+			delete(r.files, topic)
+		}
+		f, err = r.open(topic, hour)
+		if err != nil {
+			return err
+		}
+		r.files[topic] = f
+	}
+
+	if _, err := f.buf.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	f.dirty = true
+	return nil
+}
+
+// Close stops the background flush, then flushes and closes every open file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.closed {
+		r.closed = true
+		close(r.done)
+	}
+
+	var firstErr error
+	for topic, f := range r.files {
+		if err := f.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(r.files, topic)
+	}
+	return firstErr
+}
+
+// open opens the file for a topic and hour, appending a new gzip member if
+// the file already exists, e.g. after a restart within the same hour
+func (r *Recorder) open(topic string, hour time.Time) (*file, error) {
+	dir := filepath.Join(r.dir, sanitize(topic))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, hour.Format(fileTimeLayout)+fileExtension)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening recording: %w", err)
+	}
+
+	gz := gzip.NewWriter(f)
+	return &file{hour: hour, f: f, gz: gz, buf: bufio.NewWriter(gz)}, nil
+}
+
+// flush pushes buffered lines through gzip to disk so a crash loses at most
+// flushInterval worth of frames
+func (f *file) flush() error {
+	if err := f.buf.Flush(); err != nil {
+		return err
+	}
+	f.dirty = false
+	return f.gz.Flush()
+}
+
+func (f *file) close() error {
+	if err := f.buf.Flush(); err != nil {
+		f.f.Close()
+		return err
+	}
+	if err := f.gz.Close(); err != nil {
+		f.f.Close()
+		return err
+	}
+	return f.f.Close()
+}
+
+// sanitize makes a topic safe to use as a directory name
+func sanitize(topic string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, topic)
+}
@@ -0,0 +1,135 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readRecords reads the records on disk for a topic and hour, including those
+// of a file that is still open
+func readRecords(t *testing.T, dir, topic string, hour time.Time) []Record {
+	t.Helper()
+	f, err := os.Open(filepath.Join(dir, topic, hour.Format(fileTimeLayout)+fileExtension))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		// Nothing flushed yet
+		return nil
+	}
+	var records []Record
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	// An open file ends without a gzip trailer, which is reported as an
+	// unexpected EOF after the flushed lines
+	return records
+}
+
+func TestFlushQuietTopic(t *testing.T) {
+	dir := t.TempDir()
+	r, err := newRecorder(dir, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// A single frame reaches the disk without another frame of its topic
+	now := time.Now().UTC()
+	if err := r.Record("trade", now, []byte(`{"price":100}`)); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(readRecords(t, dir, "trade", now.Truncate(time.Hour))) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the frame to be flushed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFlushClosesPastHours(t *testing.T) {
+	dir := t.TempDir()
+	r, err := newRecorder(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	hour := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	if err := r.Record("trade", hour.Add(time.Minute), []byte(`{"price":100}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.flush(hour); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.files) != 1 {
+		t.Fatal("Expected the file of the current hour to stay open")
+	}
+
+	// Once the hour has passed the file is closed with its gzip trailer
+	if err := r.flush(hour.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.files) != 0 {
+		t.Error("Expected the file of the past hour to be closed")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "trade", hour.Format(fileTimeLayout)+fileExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(gz); err != nil {
+		t.Errorf("Expected a complete gzip file, got %v", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	dir := t.TempDir()
+	r, err := newRecorder(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hour := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for _, topic := range []string{"trade", "book"} {
+		if err := r.Record(topic, hour, []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, topic := range []string{"trade", "book"} {
+		if n := len(readRecords(t, dir, topic, hour)); n != 1 {
+			t.Errorf("Expected 1 %s record after closing, got %d", topic, n)
+		}
+	}
+	if err := r.Record("trade", hour, []byte(`{}`)); err == nil {
+		t.Error("Expected recording after closing to fail")
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("Expected closing twice to succeed, got %v", err)
+	}
+}
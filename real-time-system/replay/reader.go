@@ -0,0 +1,172 @@
+package replay
+
+import (
+	"bufio"
+	"compress/gzip"
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/recorder"
+)
+
+const maxLineSize = 16 << 20 // Large enough for a full orderbook.500 snapshot
+
+// Reader reads every record of a recording in receive time order, merging
+// the hourly files of every topic
+type Reader struct {
+	streams streamHeap
+}
+
+// stream reads the files of a single topic one after the other
+type stream struct {
+	files   []string
+	f       *os.File
+	gz      *gzip.Reader
+	scanner *bufio.Scanner
+	next    recorder.Record
+}
+
+// Open opens a recording directory as written by recorder.Recorder. If
+// topics are given only those are read, otherwise every topic is.
+func Open(dir string, topics ...string) (*Reader, error) {
+	if len(topics) == 0 {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				topics = append(topics, entry.Name())
+			}
+		}
+	}
+
+	r := &Reader{}
+	for _, topic := range topics {
+		files, err := filepath.Glob(filepath.Join(dir, topic, "*.jsonl.gz"))
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		// File names are UTC hours, so lexical order is chronological
+		sort.Strings(files)
+
+		s := &stream{files: files}
+		ok, err := s.advance()
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		if ok {
+			r.streams = append(r.streams, s)
+		}
+	}
+	heap.Init(&r.streams)
+	return r, nil
+}
+
+// Next returns the next record across all topics, or io.EOF at the end of the recording
+func (r *Reader) Next() (recorder.Record, error) {
+	if len(r.streams) == 0 {
+		return recorder.Record{}, io.EOF
+	}
+
+	s := r.streams[0]
+	record := s.next
+	ok, err := s.advance()
+	if err != nil {
+		return recorder.Record{}, err
+	}
+	if ok {
+		heap.Fix(&r.streams, 0)
+	} else {
+		heap.Pop(&r.streams)
+	}
+	return record, nil
+}
+
+// Close closes every open file
+func (r *Reader) Close() error {
+	for _, s := range r.streams {
+		s.closeFile()
+	}
+	r.streams = nil
+	return nil
+}
+
+// advance reads the next record of the stream into s.next, opening the next
+// file when the current one is exhausted. It returns false at the end.
+func (s *stream) advance() (bool, error) {
+	for {
+		if s.scanner == nil {
+			if len(s.files) == 0 {
+				return false, nil
+			}
+			if err := s.openFile(s.files[0]); err != nil {
+				return false, err
+			}
+			s.files = s.files[1:]
+		}
+
+		if s.scanner.Scan() {
+			s.next = recorder.Record{}
+			if err := json.Unmarshal(s.scanner.Bytes(), &s.next); err != nil {
+				return false, fmt.Errorf("parsing %s: %w", s.f.Name(), err)
+			}
+			return true, nil
+		}
+
+		err := s.scanner.Err()
+		name := s.f.Name()
+		s.closeFile()
+		// A file cut short by a crash still yields every flushed record
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return false, fmt.Errorf("reading %s: %w", name, err)
+		}
+	}
+}
+
+func (s *stream) openFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	s.f = f
+	s.gz = gz
+	s.scanner = bufio.NewScanner(gz)
+	s.scanner.Buffer(make([]byte, 64<<10), maxLineSize)
+	return nil
+}
+
+func (s *stream) closeFile() {
+	if s.f != nil {
+		s.gz.Close()
+		s.f.Close()
+	}
+	s.f, s.gz, s.scanner = nil, nil, nil
+}
+
+// streamHeap orders streams by the receive time of their next record
+type streamHeap []*stream
+
+func (h streamHeap) Len() int            { return len(h) }
+func (h streamHeap) Less(i, j int) bool  { return h[i].next.ReceivedAt < h[j].next.ReceivedAt }
+func (h streamHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *streamHeap) Push(x interface{}) { *h = append(*h, x.(*stream)) }
+func (h *streamHeap) Pop() interface{} {
+	old := *h
+	s := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return s
+}
@@ -0,0 +1,96 @@
+package replay
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/clock"
+)
+
+// Handler processes a recorded frame, e.g. bybitconnector.Client.HandleRecorded
+type Handler func(receivedAt time.Time, message []byte) error
+
+// Replayer feeds recorded frames to a handler at a configurable pace while
+// moving a simulated clock along with the recording
+type Replayer struct {
+	// Speed is the pacing relative to the recording: 1 replays at the original
+	// pace, 10 ten times faster and 0 as fast as possible
+	Speed float64
+	// Clock is set to the receive time of each frame before it is handled
+	Clock *clock.Simulated
+	// Step, if set, is called after each frame is handled with its receive
+	// time, e.g. engine.Engine.Step, and returns how long until it is due
+	// again, 0 if it isn't. A due step runs before any later frame.
+	Step func(now time.Time) time.Duration
+}
+
+// Run replays every record from the reader and returns nil at the end of the
+// recording, the first error from the handler or ctx's error once it is
+// cancelled
+func (p *Replayer) Run(ctx context.Context, r *Reader, handler Handler) error {
+	var wallStart time.Time
+	var recordStart time.Time
+	var due time.Time // When Step is due again, zero if it isn't
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		record, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		receivedAt := time.Unix(0, record.ReceivedAt)
+		if wallStart.IsZero() {
+			wallStart = time.Now()
+			recordStart = receivedAt
+		}
+
+		// Wait until the frame's offset in the recording, scaled by speed
+		if p.Speed > 0 {
+			offset := time.Duration(float64(receivedAt.Sub(recordStart)) / p.Speed)
+			if wait := time.Until(wallStart.Add(offset)); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+
+		// Steps that fall due between two frames run at their own time
+		for !due.IsZero() && !due.After(receivedAt) {
+			due = p.step(due)
+		}
+
+		if p.Clock != nil {
+			p.Clock.Set(receivedAt)
+		}
+		if err := handler(receivedAt, record.Message); err != nil {
+			return err
+		}
+		due = p.step(receivedAt)
+	}
+}
+
+// step calls Step at now and returns when it is due again, zero if it isn't
+func (p *Replayer) step(now time.Time) time.Time {
+	if p.Step == nil {
+		return time.Time{}
+	}
+	if p.Clock != nil {
+		p.Clock.Set(now)
+	}
+	wait := p.Step(now)
+	if wait <= 0 {
+		return time.Time{}
+	}
+	return now.Add(wait)
+}
@@ -0,0 +1,169 @@
+package replay
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/bybitconnector"
+	"github.com/369geofreeman/inventory-control/real-time-system/clock"
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+	"github.com/369geofreeman/inventory-control/real-time-system/recorder"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := recorder.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Frames straddle an hour boundary and are recorded out of topic order
+	start := time.Date(2023, 11, 14, 22, 59, 59, 0, time.UTC)
+	frames := []struct {
+		topic   string
+		offset  time.Duration
+		message string
+	}{
+		{"orderbook.50.BTCUSDT", 0, `{"topic":"orderbook.50.BTCUSDT","type":"snapshot","ts":1700002799000,"data":{"s":"BTCUSDT","b":[["100","1"]],"a":[["102","1"]],"u":10,"seq":100}}`},
+		{"tickers.BTCUSDT", 100 * time.Millisecond, `{"topic":"tickers.BTCUSDT","type":"snapshot","ts":1700002799100,"data":{"symbol":"BTCUSDT","lastPrice":"101"}}`},
+		{"orderbook.50.BTCUSDT", 2 * time.Second, `{"topic":"orderbook.50.BTCUSDT","type":"delta","ts":1700002801000,"data":{"s":"BTCUSDT","b":[["101","2"]],"a":[],"u":11,"seq":101}}`},
+		{"publicTrade.BTCUSDT", 1500 * time.Millisecond, `{"topic":"publicTrade.BTCUSDT","type":"snapshot","ts":1700002800500,"data":[{"T":1700002800500,"s":"BTCUSDT","S":"Buy","v":"0.1","p":"101.5","BT":false}]}`},
+		{"", 3 * time.Second, `not json`},
+	}
+	for _, frame := range frames {
+		if err := rec.Record(frame.topic, start.Add(frame.offset), []byte(frame.message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	source, sim, err := NewSource(dir, 0, bybitconnector.Config{Symbols: []string{"BTCUSDT"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed, _ := source.Feed("BTCUSDT")
+	var snapshots []marketdata.MarketSnapshot
+	source.SetStep(func(time.Time) time.Duration {
+		snapshots = append(snapshots, feed.Snapshot())
+		return 0
+	})
+	if err := source.Connect(context.Background()); err != nil {
+		t.Fatalf("Unexpected replay error: %v", err)
+	}
+
+	// One step per frame and a last one once the recording is exhausted
+	if len(snapshots) != len(frames)+1 {
+		t.Fatalf("Expected %d steps, got %d", len(frames)+1, len(snapshots))
+	}
+	if snapshots[len(frames)].MidPrice != 0 {
+		t.Error("Expected the feed to be reset at the end of the recording")
+	}
+
+	snapshot := snapshots[len(frames)-1]
+	if math.Abs(snapshot.MidPrice-101.5) > 1e-9 {
+		t.Errorf("Expected mid price 101.5 after the delta, got %f", snapshot.MidPrice)
+	}
+	if snapshot.LastPrice != 101 {
+		t.Errorf("Expected last price 101, got %f", snapshot.LastPrice)
+	}
+	if !snapshot.IsTradeReady || !snapshot.IsTickerReady {
+		t.Error("Expected trades and tickers to have been replayed")
+	}
+
+	// Local timestamps follow the recording, not the wall clock
+	if !sim.Now().Equal(start.Add(3 * time.Second)) {
+		t.Errorf("Expected simulated clock at the last frame, got %v", sim.Now())
+	}
+	if !snapshot.UpdatedAt.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("Expected last update at the delta's receive time, got %v", snapshot.UpdatedAt)
+	}
+}
+
+func TestReplayPacing(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := recorder.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := rec.Record("tickers.BTCUSDT", start.Add(time.Duration(i)*100*time.Millisecond), []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec.Close()
+
+	reader, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	// 200ms of recording at 4x takes about 50ms
+	replayer := Replayer{Speed: 4}
+	began := time.Now()
+	count := 0
+	err = replayer.Run(context.Background(), reader, func(time.Time, []byte) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 frames, got %d", count)
+	}
+	if elapsed := time.Since(began); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected replay to take about 50ms, took %s", elapsed)
+	}
+}
+
+func TestReplayStepsWhenDue(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := recorder.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2023, 11, 14, 22, 0, 0, 0, time.UTC)
+	for _, offset := range []time.Duration{0, 250 * time.Millisecond} {
+		if err := rec.Record("tickers.BTCUSDT", start.Add(offset), []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec.Close()
+
+	reader, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	// A step due every 100ms runs between the frames at its own time
+	sim := clock.NewSimulated(time.Time{})
+	var steps []time.Duration
+	replayer := Replayer{Clock: sim, Step: func(now time.Time) time.Duration {
+		if !sim.Now().Equal(now) {
+			t.Errorf("Expected the clock at %v while stepping, got %v", now, sim.Now())
+		}
+		steps = append(steps, now.Sub(start))
+		return 100 * time.Millisecond
+	}}
+	err = replayer.Run(context.Background(), reader, func(time.Time, []byte) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond, 250 * time.Millisecond}
+	if len(steps) != len(expected) {
+		t.Fatalf("Expected steps at %v, got %v", expected, steps)
+	}
+	for i := range expected {
+		if steps[i] != expected[i] {
+			t.Errorf("Expected steps at %v, got %v", expected, steps)
+			break
+		}
+	}
+}
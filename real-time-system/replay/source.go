@@ -0,0 +1,68 @@
+package replay
+
+import (
+	"context"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/bybitconnector"
+	"github.com/369geofreeman/inventory-control/real-time-system/clock"
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+)
+
+var _ marketdata.Source = (*Source)(nil)
+
+// Source replays a Bybit recording through a bybitconnector.Client so
+// consumers see exactly the feeds they would have seen live.
+// Source implements marketdata.Source.
+type Source struct {
+	*bybitconnector.Client
+	dir      string
+	replayer Replayer
+}
+
+// NewSource creates a source replaying the recording in dir for the given
+// config at the given speed. The config's Clock is replaced with the
+// simulated clock, which is returned so callers can follow replay time.
+func NewSource(dir string, speed float64, config bybitconnector.Config) (*Source, *clock.Simulated, error) {
+	sim := clock.NewSimulated(time.Time{})
+	config.Clock = sim
+
+	client, err := bybitconnector.NewClient(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Source{
+		Client:   client,
+		dir:      dir,
+		replayer: Replayer{Speed: speed, Clock: sim},
+	}, sim, nil
+}
+
+// SetStep sets a function called after every replayed frame and whenever
+// the wait it returns expires, e.g. engine.Engine.Step, so the consumer
+// evaluates every frame in order instead of racing the replay
+func (s *Source) SetStep(step func(now time.Time) time.Duration) {
+	s.replayer.Step = step
+}
+
+// Connect replays the whole recording and returns once it is exhausted or
+// ctx is cancelled. At the end of the recording every feed is reset, like
+// on a disconnect, and stepped once more so quotes are pulled.
+func (s *Source) Connect(ctx context.Context) error {
+	reader, err := Open(s.dir)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := s.replayer.Run(ctx, reader, s.Client.HandleRecorded); err != nil {
+		return err
+	}
+	for _, feed := range s.Feeds() {
+		feed.Reset()
+	}
+	if s.replayer.Step != nil {
+		s.replayer.Step(s.replayer.Clock.Now())
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package ring
+
+// Buffer is a fixed capacity FIFO that overwrites its oldest element when
+// full. It never allocates after construction.
+// Buffer is not safe for concurrent use.
+type Buffer[T any] struct {
+	items []T
+	head  int // Index of the oldest element
+	len   int
+}
+
+// NewBuffer creates a buffer holding at most capacity elements
+func NewBuffer[T any](capacity int) *Buffer[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Buffer[T]{items: make([]T, capacity)}
+}
+
+// Len returns the number of elements in the buffer
+func (b *Buffer[T]) Len() int {
+	return b.len
+}
+
+// Cap returns the maximum number of elements in the buffer
+func (b *Buffer[T]) Cap() int {
+	return len(b.items)
+}
+
+// Full reports whether the next Push evicts the oldest element
+func (b *Buffer[T]) Full() bool {
+	return b.len == len(b.items)
+}
+
+// Push appends v as the newest element. If the buffer was full the oldest
+// element is evicted and returned with ok set.
+func (b *Buffer[T]) Push(v T) (evicted T, ok bool) {
+	if b.Full() {
+		evicted, ok = b.items[b.head], true
+		b.items[b.head] = v
+		b.head = b.index(1)
+		return evicted, ok
+	}
+	b.items[b.index(b.len)] = v
+	b.len++
+	return evicted, false
+}
+
+// PopFront removes and returns the oldest element
+func (b *Buffer[T]) PopFront() (v T, ok bool) {
+	if b.len == 0 {
+		return v, false
+	}
+	var zero T
+	v = b.items[b.head]
+	b.items[b.head] = zero
+	b.head = b.index(1)
+	b.len--
+	return v, true
+}
+
+// Front returns the oldest element
+func (b *Buffer[T]) Front() (v T, ok bool) {
+	if b.len == 0 {
+		return v, false
+	}
+	return b.items[b.head], true
+}
+
+// Back returns the newest element
+func (b *Buffer[T]) Back() (v T, ok bool) {
+	if b.len == 0 {
+		return v, false
+	}
+	return b.items[b.index(b.len-1)], true
+}
+
+// At returns the i-th element, 0 being the oldest. It panics if i is out of range.
+func (b *Buffer[T]) At(i int) T {
+	if i < 0 || i >= b.len {
+		panic("ring: index out of range")
+	}
+	return b.items[b.index(i)]
+}
+
+// Do calls f for every element from oldest to newest
+func (b *Buffer[T]) Do(f func(T)) {
+	for i := 0; i < b.len; i++ {
+		f(b.items[b.index(i)])
+	}
+}
+
+// Reset removes every element
+func (b *Buffer[T]) Reset() {
+	var zero T
+	for i := range b.items {
+		b.items[i] = zero
+	}
+	b.head = 0
+	b.len = 0
+}
+
+// index returns the slice index of the i-th element
+func (b *Buffer[T]) index(i int) int {
+	i += b.head
+	if i >= len(b.items) {
+		i -= len(b.items)
+	}
+	return i
+}
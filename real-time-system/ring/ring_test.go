@@ -0,0 +1,114 @@
+package ring
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func contents(b *Buffer[int]) []int {
+	var items []int
+	b.Do(func(v int) { items = append(items, v) })
+	return items
+}
+
+func TestBufferWrapsAround(t *testing.T) {
+	b := NewBuffer[int](3)
+	for i := 1; i <= 3; i++ {
+		if _, ok := b.Push(i); ok {
+			t.Fatalf("Unexpected eviction pushing %d", i)
+		}
+	}
+	if !b.Full() {
+		t.Fatal("Expected the buffer to be full")
+	}
+
+	evicted, ok := b.Push(4)
+	if !ok || evicted != 1 {
+		t.Errorf("Expected 1 to be evicted, got %d, %v", evicted, ok)
+	}
+	if got := contents(b); !reflect.DeepEqual(got, []int{2, 3, 4}) {
+		t.Errorf("Expected [2 3 4], got %v", got)
+	}
+	if front, _ := b.Front(); front != 2 {
+		t.Errorf("Expected front 2, got %d", front)
+	}
+	if back, _ := b.Back(); back != 4 {
+		t.Errorf("Expected back 4, got %d", back)
+	}
+	if b.At(1) != 3 {
+		t.Errorf("Expected At(1) to be 3, got %d", b.At(1))
+	}
+
+	if v, ok := b.PopFront(); !ok || v != 2 {
+		t.Errorf("Expected to pop 2, got %d, %v", v, ok)
+	}
+	b.Push(5)
+	if got := contents(b); !reflect.DeepEqual(got, []int{3, 4, 5}) {
+		t.Errorf("Expected [3 4 5], got %v", got)
+	}
+
+	b.Reset()
+	if b.Len() != 0 || b.Cap() != 3 {
+		t.Errorf("Expected an empty buffer of capacity 3, got len %d cap %d", b.Len(), b.Cap())
+	}
+	if _, ok := b.PopFront(); ok {
+		t.Error("Expected nothing to pop from an empty buffer")
+	}
+	if _, ok := b.Back(); ok {
+		t.Error("Expected no back element in an empty buffer")
+	}
+}
+
+func TestBufferPushDoesNotAllocate(t *testing.T) {
+	b := NewBuffer[float64](8)
+	allocs := testing.AllocsPerRun(100, func() {
+		b.Push(1)
+		b.PopFront()
+		b.Push(2)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+func TestStatsMatchesDirectCalculation(t *testing.T) {
+	values := []float64{30000.5, 30001, 29999.5, 30002, 30000, 30003.5, 29998, 30001.5}
+	window := 3
+
+	var s Stats
+	for i, v := range values {
+		s.Add(v)
+		if i >= window {
+			s.Remove(values[i-window])
+		}
+
+		start := i - window + 1
+		if start < 0 {
+			start = 0
+		}
+		var sum, sumOfSquares float64
+		for _, w := range values[start : i+1] {
+			sum += w
+		}
+		mean := sum / float64(i+1-start)
+		for _, w := range values[start : i+1] {
+			sumOfSquares += (w - mean) * (w - mean)
+		}
+		variance := sumOfSquares / float64(i+1-start)
+
+		if s.Count() != i+1-start {
+			t.Errorf("Step %d: expected count %d, got %d", i, i+1-start, s.Count())
+		}
+		if math.Abs(s.Mean()-mean) > 1e-9 || math.Abs(s.Variance()-variance) > 1e-6 {
+			t.Errorf("Step %d: expected mean %f variance %f, got %f %f", i, mean, variance, s.Mean(), s.Variance())
+		}
+	}
+
+	for i := len(values) - window; i < len(values); i++ {
+		s.Remove(values[i])
+	}
+	if s.Count() != 0 || s.Mean() != 0 || s.StdDev() != 0 {
+		t.Errorf("Expected empty stats, got %+v", s)
+	}
+}
@@ -0,0 +1,63 @@
+package ring
+
+import "math"
+
+// Stats keeps the running mean and variance of a sliding window of values
+// with Welford's algorithm, so adding or removing a value is O(1)
+type Stats struct {
+	n    int
+	mean float64
+	m2   float64 // Sum of squared deviations from the mean
+}
+
+// Add adds x to the window
+func (s *Stats) Add(x float64) {
+	s.n++
+	d := x - s.mean
+	s.mean += d / float64(s.n)
+	s.m2 += d * (x - s.mean)
+}
+
+// Remove removes x, which must have been added before, from the window
+func (s *Stats) Remove(x float64) {
+	if s.n <= 1 {
+		s.Reset()
+		return
+	}
+	s.n--
+	d := x - s.mean
+	s.mean -= d / float64(s.n)
+	s.m2 -= d * (x - s.mean)
+	if s.m2 < 0 {
+		// Rounding error when every remaining value is equal
+		s.m2 = 0
+	}
+}
+
+// Reset empties the window
+func (s *Stats) Reset() {
+	*s = Stats{}
+}
+
+// Count returns the number of values in the window
+func (s *Stats) Count() int {
+	return s.n
+}
+
+// Mean returns the mean of the window, 0 if it is empty
+func (s *Stats) Mean() float64 {
+	return s.mean
+}
+
+// Variance returns the population variance of the window
+func (s *Stats) Variance() float64 {
+	if s.n == 0 {
+		return 0
+	}
+	return s.m2 / float64(s.n)
+}
+
+// StdDev returns the population standard deviation of the window
+func (s *Stats) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
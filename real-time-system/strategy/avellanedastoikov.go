@@ -0,0 +1,188 @@
+package strategy
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+	"github.com/369geofreeman/inventory-control/real-time-system/volatility"
+)
+
+// ASConfig configures the Avellaneda-Stoikov strategy. Prices are in the
+// quote currency, time is in seconds and inventory is in lots of Size, as
+// every fill in the model is one unit.
+type ASConfig struct {
+	Gamma float64 // Risk aversion γ, required
+	K     float64 // Decay k of the fill intensity A·exp(-kδ) with the distance δ of a quote from the mid-price, required
+	Size  float64 // Size of each quote in the base currency, required
+
+	// Calibrated replaces K with the k calibrated by the feed once it is ready
+	Calibrated bool
+
+	Target float64 // Base inventory quotes are symmetric around, 0 by default
+
+	// Horizon is the length of a session of the finite-horizon variant, the
+	// inventory penalty shrinks to 0 at its end. Sessions divide the day from
+	// midnight UTC, e.g. 8h sessions end at 00:00, 08:00 and 16:00. 0 selects
+	// the infinite-horizon variant.
+	Horizon time.Duration
+
+	// MaxInventory bounds the inventory away from Target in the base
+	// currency, the side that would grow it beyond is not quoted. Required for
+	// the infinite horizon.
+	MaxInventory float64
+
+	// Omega is the discount rate ω per second of the infinite-horizon
+	// variant. 0 uses ½γ²σ²(Q+1)² as in the paper, with Q the max inventory
+	// in lots, at which volatility cancels out of the quotes.
+	Omega float64
+}
+
+// validate checks the config
+func (c ASConfig) validate() error {
+	if c.Gamma <= 0 {
+		return errors.New("gamma should be greater than 0")
+	}
+	if c.K <= 0 {
+		return errors.New("k should be greater than 0")
+	}
+	if c.Size <= 0 {
+		return errors.New("size should be greater than 0")
+	}
+	if c.Horizon < 0 {
+		return errors.New("horizon should not be negative")
+	}
+	if c.MaxInventory < 0 || c.Omega < 0 {
+		return errors.New("max inventory and omega should not be negative")
+	}
+	if c.Horizon == 0 && c.MaxInventory == 0 {
+		return errors.New("max inventory is required for the infinite horizon")
+	}
+	return nil
+}
+
+// AvellanedaStoikov quotes around a reservation price skewed against the
+// inventory, see Avellaneda and Stoikov, "High-frequency trading in a limit
+// order book" (2008)
+type AvellanedaStoikov struct {
+	config ASConfig
+}
+
+var _ Strategy = (*AvellanedaStoikov)(nil)
+
+// NewAvellanedaStoikov creates an Avellaneda-Stoikov strategy
+func NewAvellanedaStoikov(config ASConfig) (*AvellanedaStoikov, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &AvellanedaStoikov{config: config}, nil
+}
+
+// Name returns "avellaneda-stoikov"
+func (s *AvellanedaStoikov) Name() string {
+	return "avellaneda-stoikov"
+}
+
+// Quote returns a bid and ask around the reservation price, each at the
+// optimal distance for the fill intensity
+func (s *AvellanedaStoikov) Quote(input Input) (Quotes, error) {
+	c := s.config
+	price := input.Market.FairValue
+	if price <= 0 {
+		return Quotes{}, errors.New("fair value should be greater than 0")
+	}
+	sigma := priceVolatility(input.Market.Volatility, input.Market.Forecast, price)
+	maxLots := c.MaxInventory / c.Size
+	q := lots(input.Inventory.Base, c.Target, c.Size, maxLots)
+	_, k := fillIntensity(input.Market, c.Calibrated, 0, c.K)
+	diagnostics := map[string]float64{
+		"inventory": q,
+		"sigma":     sigma,
+		"k":         k,
+	}
+
+	// Reservation prices at which buying or selling one more lot is neutral
+	var reservationBid, reservationAsk float64
+	if c.Horizon > 0 {
+		remaining := remaining(input.Now, c.Horizon).Seconds()
+		risk := c.Gamma * sigma * sigma * remaining
+		reservation := price - q*risk
+		reservationBid = reservation - risk/2
+		reservationAsk = reservation + risk/2
+		diagnostics["remaining"] = remaining
+	} else {
+		// Both terms are scaled by γ²σ² with the default ω, so it cancels
+		bidTerm, askTerm := -1-2*q, 1-2*q
+		denominator := (maxLots+1)*(maxLots+1) - q*q
+		if c.Omega > 0 {
+			variance := c.Gamma * c.Gamma * sigma * sigma
+			bidTerm *= variance
+			askTerm *= variance
+			denominator = 2*c.Omega - variance*q*q
+		}
+		if denominator <= 0 {
+			return Quotes{}, errors.New("omega is too small for the max inventory")
+		}
+		reservationBid = price + math.Log(1+bidTerm/denominator)/c.Gamma
+		reservationAsk = price + math.Log(1+askTerm/denominator)/c.Gamma
+	}
+	diagnostics["reservation_price"] = (reservationBid + reservationAsk) / 2
+
+	// Distance from the reservation price that maximizes the expected utility of a fill
+	offset := math.Log(1+c.Gamma/k) / c.Gamma
+	diagnostics["half_spread"] = (reservationAsk-reservationBid)/2 + offset
+
+	canBuy := maxLots == 0 || q < maxLots
+	canSell := maxLots == 0 || q > -maxLots
+	return quotes(reservationBid-offset, reservationAsk+offset, c.Size, canBuy, canSell, diagnostics), nil
+}
+
+// quotes returns a bid and ask of the given size, leaving out a side that
+// may not be quoted or has no valid price
+func quotes(bid, ask, size float64, canBuy, canSell bool, diagnostics map[string]float64) Quotes {
+	quotes := Quotes{Diagnostics: diagnostics}
+	if canBuy && bid > 0 && !math.IsInf(bid, 0) && !math.IsNaN(bid) {
+		quotes.Bids = []Quote{{Price: bid, Size: size}}
+	}
+	if canSell && ask > 0 && !math.IsInf(ask, 0) && !math.IsNaN(ask) {
+		quotes.Asks = []Quote{{Price: ask, Size: size}}
+	}
+	return quotes
+}
+
+// priceVolatility converts the annualized volatility of the snapshot, or its
+// forecast where available, to a volatility of the price per √second
+func priceVolatility(realized, forecast, price float64) float64 {
+	sigma := realized
+	if forecast > 0 {
+		// Quotes rest over the forecast horizon, so price its risk rather than the past
+		sigma = forecast
+	}
+	return volatility.Scale(sigma, volatility.Year, time.Second) * price
+}
+
+// fillIntensity returns the fill intensity calibrated by the feed if enabled
+// and ready, or the configured a and k otherwise
+func fillIntensity(market marketdata.MarketSnapshot, calibrated bool, a, k float64) (float64, float64) {
+	if calibrated && market.IsIntensityReady && market.Intensity.A > 0 && market.Intensity.K > 0 {
+		return market.Intensity.A, market.Intensity.K
+	}
+	return a, k
+}
+
+// lots returns the inventory in excess of target in lots of size, clamped
+// to ±max if max is set
+func lots(base, target, size, max float64) float64 {
+	q := (base - target) / size
+	if max > 0 {
+		q = math.Max(-max, math.Min(q, max))
+	}
+	return q
+}
+
+// remaining returns the time left in the session of the given length, with
+// sessions aligned to midnight UTC
+func remaining(now time.Time, session time.Duration) time.Duration {
+	return now.Truncate(session).Add(session).Sub(now)
+}
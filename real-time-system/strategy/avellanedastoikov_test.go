@@ -0,0 +1,156 @@
+package strategy
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/volatility"
+)
+
+// asInput returns an input at a price of 100 with a price volatility of
+// sigma per √second, holding base and 30 minutes into the day
+func asInput(sigma, base float64) Input {
+	in := input()
+	in.Market.FairValue = 100
+	in.Market.Volatility = volatility.Scale(sigma/100, time.Second, volatility.Year)
+	in.Inventory.Base = base
+	in.Now = time.Date(2023, 11, 14, 0, 30, 0, 0, time.UTC)
+	return in
+}
+
+func assertQuotes(t *testing.T, quotes Quotes, bid, ask float64) {
+	t.Helper()
+	if b, ok := quotes.BestBid(); !ok || math.Abs(b.Price-bid) > 1e-9 {
+		t.Errorf("Expected bid %f, got %+v", bid, quotes.Bids)
+	}
+	if a, ok := quotes.BestAsk(); !ok || math.Abs(a.Price-ask) > 1e-9 {
+		t.Errorf("Expected ask %f, got %+v", ask, quotes.Asks)
+	}
+}
+
+func TestAvellanedaStoikovConfig(t *testing.T) {
+	invalid := []ASConfig{
+		{K: 1.5, Size: 1, MaxInventory: 1},
+		{Gamma: 0.1, Size: 1, MaxInventory: 1},
+		{Gamma: 0.1, K: 1.5, MaxInventory: 1},
+		{Gamma: 0.1, K: 1.5, Size: 1, Horizon: -time.Hour},
+		{Gamma: 0.1, K: 1.5, Size: 1}, // Infinite horizon without a max inventory
+	}
+	for i, config := range invalid {
+		if _, err := NewAvellanedaStoikov(config); err == nil {
+			t.Errorf("Expected config %d to be rejected", i)
+		}
+	}
+}
+
+func TestAvellanedaStoikovFiniteHorizon(t *testing.T) {
+	s, err := NewAvellanedaStoikov(ASConfig{
+		Gamma:   0.1,
+		K:       1.5,
+		Size:    0.5,
+		Target:  1,
+		Horizon: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := math.Log(1+0.1/1.5) / 0.1
+
+	// 1800s remain, so γσ²(T-t) = 0.1 * 0.01 * 1800 = 1.8
+	quotes, err := s.Quote(asInput(0.1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertQuotes(t, quotes, 100-0.9-offset, 100+0.9+offset)
+	if size := quotes.Bids[0].Size; size != 0.5 {
+		t.Errorf("Expected size 0.5, got %f", size)
+	}
+	if remaining := quotes.Diagnostics["remaining"]; remaining != 1800 {
+		t.Errorf("Expected 1800s remaining, got %f", remaining)
+	}
+
+	// Two lots long skews the reservation price down by 2 * 1.8
+	quotes, _ = s.Quote(asInput(0.1, 2))
+	assertQuotes(t, quotes, 96.4-0.9-offset, 96.4+0.9+offset)
+	if r := quotes.Diagnostics["reservation_price"]; math.Abs(r-96.4) > 1e-9 {
+		t.Errorf("Expected reservation price 96.4, got %f", r)
+	}
+
+	// The inventory penalty vanishes at the end of the session
+	in := asInput(0.1, 2)
+	in.Now = time.Date(2023, 11, 14, 0, 59, 59, 999999999, time.UTC)
+	quotes, _ = s.Quote(in)
+	assertQuotes(t, quotes, 100-offset, 100+offset)
+}
+
+func TestAvellanedaStoikovInfiniteHorizon(t *testing.T) {
+	s, err := NewAvellanedaStoikov(ASConfig{
+		Gamma:        0.1,
+		K:            1.5,
+		Size:         1,
+		MaxInventory: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := math.Log(1+0.1/1.5) / 0.1
+
+	// With the default ω the denominator is (2+1)² - q²
+	quotes, err := s.Quote(asInput(0.1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertQuotes(t, quotes, 100+math.Log(1-1.0/9)/0.1-offset, 100+math.Log(1+1.0/9)/0.1+offset)
+
+	// Volatility cancels out with the default ω
+	other, _ := s.Quote(asInput(0.5, 0))
+	assertQuotes(t, other, quotes.Bids[0].Price, quotes.Asks[0].Price)
+
+	// Long inventory skews both quotes down
+	quotes, _ = s.Quote(asInput(0.1, 1))
+	assertQuotes(t, quotes, 100+math.Log(1-3.0/8)/0.1-offset, 100+math.Log(1-1.0/8)/0.1+offset)
+
+	// At the max inventory only the side reducing it is quoted
+	quotes, _ = s.Quote(asInput(0.1, 5))
+	if len(quotes.Bids) != 0 || len(quotes.Asks) != 1 {
+		t.Errorf("Expected only an ask at the max long inventory, got %+v", quotes)
+	}
+	quotes, _ = s.Quote(asInput(0.1, -2))
+	if len(quotes.Bids) != 1 || len(quotes.Asks) != 0 {
+		t.Errorf("Expected only a bid at the max short inventory, got %+v", quotes)
+	}
+}
+
+func TestAvellanedaStoikovOmega(t *testing.T) {
+	s, err := NewAvellanedaStoikov(ASConfig{
+		Gamma:        0.1,
+		K:            1.5,
+		Size:         1,
+		MaxInventory: 2,
+		Omega:        0.001,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// γ²σ² = 0.0001, so at q = 0 the terms are ±0.0001 / 0.002
+	quotes, err := s.Quote(asInput(0.1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := math.Log(1+0.1/1.5) / 0.1
+	assertQuotes(t, quotes, 100+math.Log(1-0.05)/0.1-offset, 100+math.Log(1+0.05)/0.1+offset)
+
+	// A fixed ω widens the quotes as volatility rises
+	wider, _ := s.Quote(asInput(0.2, 0))
+	if wider.Diagnostics["half_spread"] <= quotes.Diagnostics["half_spread"] {
+		t.Errorf("Expected a wider spread at higher volatility, got %f <= %f",
+			wider.Diagnostics["half_spread"], quotes.Diagnostics["half_spread"])
+	}
+
+	// ω must exceed ½γ²σ²q² for the quotes to exist
+	if _, err := s.Quote(asInput(10, 2)); err == nil {
+		t.Error("Expected an error when omega is too small for the inventory")
+	}
+}
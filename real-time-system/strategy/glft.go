@@ -0,0 +1,105 @@
+package strategy
+
+import (
+	"errors"
+	"math"
+)
+
+// inventoryTolerance absorbs rounding when checking a fill against the max inventory
+const inventoryTolerance = 1e-9
+
+// GLFTConfig configures the GLFT strategy. Prices are in the quote currency,
+// time is in seconds and inventory is in the base currency.
+type GLFTConfig struct {
+	Gamma float64 // Risk aversion γ, required
+	A     float64 // Fill intensity A per second of a quote at the mid-price, required
+	K     float64 // Decay k of the fill intensity A·exp(-kδ) with the distance δ of a quote from the mid-price, required
+	Size  float64 // Size of each quote in the base currency, required
+
+	// Calibrated replaces A and K with the fill intensity calibrated by the
+	// feed once it is ready
+	Calibrated bool
+
+	Target float64 // Base inventory quotes are symmetric around, 0 by default
+
+	// MaxInventory is the hard limit Q on the inventory away from Target, a
+	// side is only quoted while a fill keeps the inventory within it. Required.
+	MaxInventory float64
+}
+
+// validate checks the config
+func (c GLFTConfig) validate() error {
+	if c.Gamma <= 0 {
+		return errors.New("gamma should be greater than 0")
+	}
+	if c.A <= 0 {
+		return errors.New("a should be greater than 0")
+	}
+	if c.K <= 0 {
+		return errors.New("k should be greater than 0")
+	}
+	if c.Size <= 0 {
+		return errors.New("size should be greater than 0")
+	}
+	if c.MaxInventory < c.Size {
+		return errors.New("max inventory should be at least the size")
+	}
+	return nil
+}
+
+// GLFT quotes at the closed-form approximation of the optimal depths in the
+// stationary model of Guéant, Lehalle and Fernandez-Tapia, "Dealing with the
+// inventory risk" (2013), which bounds the inventory by Q
+type GLFT struct {
+	config GLFTConfig
+}
+
+var _ Strategy = (*GLFT)(nil)
+
+// NewGLFT creates a GLFT strategy
+func NewGLFT(config GLFTConfig) (*GLFT, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &GLFT{config: config}, nil
+}
+
+// Name returns "glft"
+func (s *GLFT) Name() string {
+	return "glft"
+}
+
+// Quote returns a bid and ask at the optimal depths from the fair value for
+// the current inventory
+func (s *GLFT) Quote(input Input) (Quotes, error) {
+	c := s.config
+	price := input.Market.FairValue
+	if price <= 0 {
+		return Quotes{}, errors.New("fair value should be greater than 0")
+	}
+	sigma := priceVolatility(input.Market.Volatility, input.Market.Forecast, price)
+	maxLots := c.MaxInventory / c.Size
+	q := lots(input.Inventory.Base, c.Target, c.Size, maxLots)
+	a, k := fillIntensity(input.Market, c.Calibrated, c.A, c.K)
+
+	// The depths are a constant half-spread plus an inventory skew
+	// δb = c1 + (2q+1)/2·c2 and δa = c1 - (2q-1)/2·c2
+	c1 := math.Log(1+c.Gamma/k) / c.Gamma
+	c2 := math.Sqrt(c.Gamma * sigma * sigma / (2 * a * k) * math.Pow(1+c.Gamma/k, 1+k/c.Gamma))
+	bidDepth := c1 + (2*q+1)/2*c2
+	askDepth := c1 - (2*q-1)/2*c2
+
+	diagnostics := map[string]float64{
+		"inventory":         q,
+		"sigma":             sigma,
+		"a":                 a,
+		"k":                 k,
+		"bid_depth":         bidDepth,
+		"ask_depth":         askDepth,
+		"reservation_price": price - q*c2,
+		"half_spread":       c1 + c2/2,
+	}
+	canBuy := q+1 <= maxLots+inventoryTolerance
+	canSell := q-1 >= -maxLots-inventoryTolerance
+	return quotes(price-bidDepth, price+askDepth, c.Size, canBuy, canSell, diagnostics), nil
+}
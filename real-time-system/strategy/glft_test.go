@@ -0,0 +1,105 @@
+package strategy
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGLFTConfig(t *testing.T) {
+	invalid := []GLFTConfig{
+		{A: 1, K: 1.5, Size: 1, MaxInventory: 1},
+		{Gamma: 0.1, K: 1.5, Size: 1, MaxInventory: 1},
+		{Gamma: 0.1, A: 1, Size: 1, MaxInventory: 1},
+		{Gamma: 0.1, A: 1, K: 1.5, MaxInventory: 1},
+		{Gamma: 0.1, A: 1, K: 1.5, Size: 1, MaxInventory: 0.5},
+	}
+	for i, config := range invalid {
+		if _, err := NewGLFT(config); err == nil {
+			t.Errorf("Expected config %d to be rejected", i)
+		}
+	}
+}
+
+func TestGLFT(t *testing.T) {
+	s, err := NewGLFT(GLFTConfig{
+		Gamma:        0.1,
+		A:            1,
+		K:            1.5,
+		Size:         0.5,
+		Target:       1,
+		MaxInventory: 1, // 2 lots
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1 := math.Log(1+0.1/1.5) / 0.1
+	c2 := math.Sqrt(0.1 * 0.01 / 3 * math.Pow(1+0.1/1.5, 1+15))
+
+	// Flat inventory quotes symmetrically around the fair value
+	quotes, err := s.Quote(asInput(0.1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertQuotes(t, quotes, 100-c1-c2/2, 100+c1+c2/2)
+	if size := quotes.Asks[0].Size; size != 0.5 {
+		t.Errorf("Expected size 0.5, got %f", size)
+	}
+
+	// One lot long skews both depths by c2, keeping the spread
+	quotes, _ = s.Quote(asInput(0.1, 1.5))
+	assertQuotes(t, quotes, 100-c1-1.5*c2, 100+c1-0.5*c2)
+	if r := quotes.Diagnostics["reservation_price"]; math.Abs(r-(100-c2)) > 1e-9 {
+		t.Errorf("Expected reservation price %f, got %f", 100-c2, r)
+	}
+
+	// Higher volatility skews harder
+	volatile, _ := s.Quote(asInput(0.4, 1.5))
+	if volatile.Asks[0].Price >= quotes.Asks[0].Price {
+		t.Errorf("Expected a lower ask at higher volatility, got %f >= %f", volatile.Asks[0].Price, quotes.Asks[0].Price)
+	}
+
+	// A side is only quoted while a fill keeps the inventory within Q
+	quotes, _ = s.Quote(asInput(0.1, 2))
+	if len(quotes.Bids) != 0 || len(quotes.Asks) != 1 {
+		t.Errorf("Expected only an ask at the max long inventory, got %+v", quotes)
+	}
+	quotes, _ = s.Quote(asInput(0.1, 0.25))
+	if len(quotes.Bids) != 1 || len(quotes.Asks) != 0 {
+		t.Errorf("Expected only a bid when a sell would breach the max short inventory, got %+v", quotes)
+	}
+}
+
+func TestGLFTCalibrated(t *testing.T) {
+	config := GLFTConfig{
+		Gamma:        0.1,
+		A:            1,
+		K:            1.5,
+		Size:         1,
+		MaxInventory: 2,
+	}
+	fixed, _ := NewGLFT(config)
+	config.Calibrated = true
+	calibrated, _ := NewGLFT(config)
+
+	// The configured intensity is used until the calibration is ready
+	in := asInput(0.1, 0)
+	in.Market.Intensity.A, in.Market.Intensity.K = 2, 0.5
+	quotes, _ := calibrated.Quote(in)
+	if a, k := quotes.Diagnostics["a"], quotes.Diagnostics["k"]; a != 1 || k != 1.5 {
+		t.Errorf("Expected the configured intensity 1/1.5 before calibration, got %f/%f", a, k)
+	}
+
+	in.Market.IsIntensityReady = true
+	quotes, _ = calibrated.Quote(in)
+	if a, k := quotes.Diagnostics["a"], quotes.Diagnostics["k"]; a != 2 || k != 0.5 {
+		t.Errorf("Expected the calibrated intensity 2/0.5, got %f/%f", a, k)
+	}
+	c1 := math.Log(1+0.1/0.5) / 0.1
+	c2 := math.Sqrt(0.1 * 0.01 / 2 * math.Pow(1+0.1/0.5, 1+5))
+	assertQuotes(t, quotes, 100-c1-c2/2, 100+c1+c2/2)
+
+	quotes, _ = fixed.Quote(in)
+	if k := quotes.Diagnostics["k"]; k != 1.5 {
+		t.Errorf("Expected the configured k 1.5 without calibration, got %f", k)
+	}
+}
@@ -0,0 +1,147 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+	"github.com/369geofreeman/inventory-control/real-time-system/optimization"
+	"github.com/lanl/clp"
+)
+
+// LPLiquidity selects the liquidity measurement the LP model optimizes with
+type LPLiquidity string
+
+const (
+	// BandLiquidity uses the thinner side of the liquidity and depth bands
+	BandLiquidity LPLiquidity = "band"
+	// CostLiquidity uses the quote notional tradable per basis point of
+	// slippage when trading CostNotional on the costlier side, and the
+	// thinner side of the depth band
+	CostLiquidity LPLiquidity = "cost"
+)
+
+// LPConfig configures the LP strategy
+type LPConfig struct {
+	Size      float64     // Size of each quote in the base currency, required
+	Liquidity LPLiquidity // Liquidity measurement, BandLiquidity by default
+
+	// CostNotional is the quote notional whose cost to trade is read from the
+	// liquidity metrics, required with CostLiquidity
+	CostNotional float64
+}
+
+// withDefaults returns the config with zero values replaced by defaults
+func (c LPConfig) withDefaults() LPConfig {
+	if c.Liquidity == "" {
+		c.Liquidity = BandLiquidity
+	}
+	return c
+}
+
+// validate checks the config after defaults have been applied
+func (c LPConfig) validate() error {
+	if c.Size <= 0 {
+		return errors.New("size should be greater than 0")
+	}
+	switch c.Liquidity {
+	case BandLiquidity:
+	case CostLiquidity:
+		if c.CostNotional <= 0 {
+			return errors.New("cost notional should be greater than 0")
+		}
+	default:
+		return fmt.Errorf("unknown liquidity %q", c.Liquidity)
+	}
+	return nil
+}
+
+// LP quotes the spread found by the linear programming model in the
+// optimization package
+type LP struct {
+	optimizer *optimization.Optimizer
+	config    LPConfig
+}
+
+var _ Strategy = (*LP)(nil)
+
+// NewLP creates a strategy quoting on both sides of the spread found by the
+// optimizer
+func NewLP(optimizer *optimization.Optimizer, config LPConfig) (*LP, error) {
+	if optimizer == nil {
+		return nil, errors.New("optimizer is required")
+	}
+	config = config.withDefaults()
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &LP{optimizer: optimizer, config: config}, nil
+}
+
+// Name returns "lp"
+func (s *LP) Name() string {
+	return "lp"
+}
+
+// Quote optimizes the spread around the fair value
+func (s *LP) Quote(input Input) (Quotes, error) {
+	market := input.Market
+	s.optimizer.AdjustEmaFactorBasedOnVolatility(market.Volatility)
+
+	volatility := market.Volatility
+	if market.Forecast > 0 {
+		// Quotes rest over the forecast horizon, so price its risk rather than the past
+		volatility = market.Forecast
+	}
+
+	liquidity, depth, err := s.liquidity(market)
+	if err != nil {
+		return Quotes{}, err
+	}
+
+	// The optimizer only reads the balances, fees are accounted for on execution
+	inventory := optimization.NewInventory(input.Inventory.Cash, input.Inventory.Base, 0)
+	bid, ask, status := s.optimizer.OptimizeSpread(market.FairValue, inventory, volatility, liquidity, depth)
+	if status != clp.Optimal {
+		// Includes the optimizer's fallback spread on invalid market data,
+		// which was not optimized for the current market
+		return Quotes{}, fmt.Errorf("optimization finished with status %d", status)
+	}
+
+	state := s.optimizer.State()
+	return Quotes{
+		Bids: []Quote{{Price: bid, Size: s.config.Size}},
+		Asks: []Quote{{Price: ask, Size: s.config.Size}},
+		Diagnostics: map[string]float64{
+			"fair_value":     market.FairValue,
+			"volatility":     volatility,
+			"liquidity":      liquidity,
+			"depth":          depth,
+			"ema_volatility": state.EmaVolatility,
+			"ema_factor":     state.EmaFactor,
+			"spread":         ask - bid,
+		},
+	}, nil
+}
+
+// liquidity returns the liquidity and depth to optimize with. Both sides are
+// quoted with the same spread, so the thinner side bounds them.
+func (s *LP) liquidity(market marketdata.MarketSnapshot) (float64, float64, error) {
+	depth := math.Min(market.BidDepth, market.AskDepth)
+	if s.config.Liquidity == BandLiquidity {
+		return math.Min(market.BidLiquidity, market.AskLiquidity), depth, nil
+	}
+
+	for _, cost := range market.LiquidityMetrics.Costs {
+		if cost.Notional != s.config.CostNotional {
+			continue
+		}
+		worst := math.Max(cost.BuyBps, cost.SellBps)
+		if worst <= 0 || math.IsInf(worst, 1) {
+			return 0, 0, fmt.Errorf("no cost to trade %g in the book", cost.Notional)
+		}
+		return cost.Notional / worst, depth, nil
+	}
+	return 0, 0, fmt.Errorf("cost to trade %g is not measured", s.config.CostNotional)
+}
@@ -0,0 +1,69 @@
+// Package strategy defines the interface quoting models implement, so they
+// can be swapped and compared without changing the engine or main loop.
+package strategy
+
+import (
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+)
+
+// Strategy turns the market and inventory state into quotes.
+// A Strategy is called from a single goroutine.
+type Strategy interface {
+	// Name identifies the strategy in logs and comparisons
+	Name() string
+	// Quote returns the quotes to rest in the market. An error means the
+	// strategy has no opinion and any resting quotes should be pulled.
+	Quote(input Input) (Quotes, error)
+}
+
+// Input is everything a strategy may base its quotes on
+type Input struct {
+	Market    marketdata.MarketSnapshot
+	Inventory Inventory
+	Now       time.Time // Current time of the engine clock, simulated during replays
+}
+
+// Inventory is the state of the market maker's balances
+type Inventory struct {
+	Cash float64 // Quote currency balance, e.g. USDT
+	Base float64 // Base currency balance, e.g. BTC
+}
+
+// Value returns the total value of the inventory in the quote currency
+func (i Inventory) Value(price float64) float64 {
+	return i.Cash + i.Base*price
+}
+
+// Quote is an order to rest at a single price
+type Quote struct {
+	Price float64
+	Size  float64 // In the base currency
+}
+
+// Quotes is the set of orders a strategy wants resting in the market
+type Quotes struct {
+	Bids []Quote // Best price first
+	Asks []Quote // Best price first
+
+	// Diagnostics are intermediate values of the model for logging and
+	// comparing strategies, e.g. the reservation price
+	Diagnostics map[string]float64
+}
+
+// BestBid returns the highest bid, or false if there are no bids
+func (q Quotes) BestBid() (Quote, bool) {
+	if len(q.Bids) == 0 {
+		return Quote{}, false
+	}
+	return q.Bids[0], true
+}
+
+// BestAsk returns the lowest ask, or false if there are no asks
+func (q Quotes) BestAsk() (Quote, bool) {
+	if len(q.Asks) == 0 {
+		return Quote{}, false
+	}
+	return q.Asks[0], true
+}
@@ -0,0 +1,149 @@
+package strategy
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/liquidity"
+	"github.com/369geofreeman/inventory-control/real-time-system/marketdata"
+	"github.com/369geofreeman/inventory-control/real-time-system/optimization"
+)
+
+// input returns an input with a ready market around 26000
+func input() Input {
+	return Input{
+		Market: marketdata.MarketSnapshot{
+			MidPrice:       26000,
+			FairValue:      26000.5,
+			Volatility:     0.8,
+			Liquidity:      0.6,
+			OrderBookDepth: 3,
+			BidLiquidity:   0.5,
+			AskLiquidity:   0.7,
+			BidDepth:       3,
+			AskDepth:       3,
+		},
+		Inventory: Inventory{Cash: 1000, Base: 0.05},
+		Now:       time.Unix(1700000000, 0),
+	}
+}
+
+func TestQuotes(t *testing.T) {
+	var quotes Quotes
+	if _, ok := quotes.BestBid(); ok {
+		t.Error("Expected no best bid without bids")
+	}
+
+	quotes = Quotes{
+		Bids: []Quote{{99, 1}, {98, 2}},
+		Asks: []Quote{{101, 1}},
+	}
+	if bid, _ := quotes.BestBid(); bid.Price != 99 {
+		t.Errorf("Expected best bid 99, got %f", bid.Price)
+	}
+	if ask, _ := quotes.BestAsk(); ask.Price != 101 {
+		t.Errorf("Expected best ask 101, got %f", ask.Price)
+	}
+
+	inventory := Inventory{Cash: 1000, Base: 2}
+	if value := inventory.Value(100); value != 1200 {
+		t.Errorf("Expected inventory value 1200, got %f", value)
+	}
+}
+
+func TestLP(t *testing.T) {
+	optimizer, err := optimization.NewOptimizer(optimization.DefaultParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewLP(nil, LPConfig{Size: 1}); err == nil {
+		t.Error("Expected a missing optimizer to be rejected")
+	}
+	if _, err := NewLP(optimizer, LPConfig{}); err == nil {
+		t.Error("Expected a zero size to be rejected")
+	}
+	if _, err := NewLP(optimizer, LPConfig{Size: 1, Liquidity: "depth"}); err == nil {
+		t.Error("Expected an unknown liquidity to be rejected")
+	}
+	if _, err := NewLP(optimizer, LPConfig{Size: 1, Liquidity: CostLiquidity}); err == nil {
+		t.Error("Expected cost liquidity without a notional to be rejected")
+	}
+
+	lp, err := NewLP(optimizer, LPConfig{Size: 0.01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	quotes, err := lp.Quote(input())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bid, okBid := quotes.BestBid()
+	ask, okAsk := quotes.BestAsk()
+	if !okBid || !okAsk {
+		t.Fatalf("Expected quotes on both sides, got %+v", quotes)
+	}
+	if bid.Price >= ask.Price || bid.Price > 26000.5 || ask.Price < 26000.5 {
+		t.Errorf("Expected quotes around the fair value 26000.5, got %f/%f", bid.Price, ask.Price)
+	}
+	if bid.Size != 0.01 || ask.Size != 0.01 {
+		t.Errorf("Expected size 0.01 on both sides, got %f/%f", bid.Size, ask.Size)
+	}
+	if v := quotes.Diagnostics["volatility"]; v != 0.8 {
+		t.Errorf("Expected volatility 0.8 in the diagnostics, got %f", v)
+	}
+	if v := quotes.Diagnostics["liquidity"]; v != 0.5 {
+		t.Errorf("Expected the thinner side's liquidity 0.5 in the diagnostics, got %f", v)
+	}
+
+	// A forecast replaces the realized volatility
+	in := input()
+	in.Market.Forecast = 1.2
+	quotes, _ = lp.Quote(in)
+	if v := quotes.Diagnostics["volatility"]; v != 1.2 {
+		t.Errorf("Expected the forecast 1.2 in the diagnostics, got %f", v)
+	}
+
+	// The optimizer's fallback spread is not quoted
+	in = input()
+	in.Market.BidLiquidity = 0
+	if quotes, err := lp.Quote(in); err == nil || len(quotes.Bids) > 0 || len(quotes.Asks) > 0 {
+		t.Errorf("Expected invalid market data to be rejected without quotes, got %+v, %v", quotes, err)
+	}
+}
+
+func TestLPCostLiquidity(t *testing.T) {
+	optimizer, err := optimization.NewOptimizer(optimization.DefaultParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp, err := NewLP(optimizer, LPConfig{Size: 0.01, Liquidity: CostLiquidity, CostNotional: 10000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the notional in the metrics there is nothing to optimize with
+	in := input()
+	if _, err := lp.Quote(in); err == nil {
+		t.Error("Expected an unmeasured cost to trade to be rejected")
+	}
+
+	// 10000 costs 4bps on the costlier side, i.e. 2500 per basis point
+	in.Market.LiquidityMetrics = liquidity.Metrics{Costs: []liquidity.Cost{
+		{Notional: 1000, BuyBps: 1, SellBps: 1},
+		{Notional: 10000, BuyBps: 2, SellBps: 4},
+	}}
+	quotes, err := lp.Quote(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := quotes.Diagnostics["liquidity"]; v != 2500 {
+		t.Errorf("Expected liquidity 2500 in the diagnostics, got %f", v)
+	}
+
+	// A book too thin to trade the notional is not quoted
+	in.Market.LiquidityMetrics.Costs[1].SellBps = math.Inf(1)
+	if _, err := lp.Quote(in); err == nil {
+		t.Error("Expected a book too thin to trade the notional to be rejected")
+	}
+}
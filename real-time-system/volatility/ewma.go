@@ -0,0 +1,74 @@
+package volatility
+
+import (
+	"math"
+	"time"
+)
+
+var _ Estimator = (*EWMAEstimator)(nil)
+
+// EWMAEstimator is the RiskMetrics exponentially weighted moving average of
+// squared close-to-close log returns, which reacts to volatility regime
+// changes faster than an equally weighted window
+type EWMAEstimator struct {
+	sampler    sampler
+	lambda     float64 // Weight of the previous variance
+	minSamples int
+
+	variance  float64
+	samples   int
+	prevClose float64
+}
+
+// NewEWMA creates an estimator with decay factor lambda that is ready after
+// minSamples returns sampled every interval
+func NewEWMA(interval time.Duration, lambda float64, minSamples int) *EWMAEstimator {
+	return &EWMAEstimator{
+		sampler:    sampler{interval: interval, maxGap: minSamples},
+		lambda:     lambda,
+		minSamples: minSamples,
+	}
+}
+
+// Update adds the price observed at time t
+func (e *EWMAEstimator) Update(t time.Time, price float64) {
+	e.sampler.update(t, price, e.onBar)
+}
+
+func (e *EWMAEstimator) onBar(bar Bar) {
+	if e.prevClose > 0 {
+		r := math.Log(bar.Close / e.prevClose)
+		if e.samples < e.minSamples {
+			// Seed with the equally weighted mean so the first returns do
+			// not dominate the estimate
+			e.variance += (r*r - e.variance) / float64(e.samples+1)
+		} else {
+			e.variance = e.lambda*e.variance + (1-e.lambda)*r*r
+		}
+		e.samples++
+	}
+	e.prevClose = bar.Close
+}
+
+// Volatility returns the volatility per interval
+func (e *EWMAEstimator) Volatility() float64 {
+	return math.Sqrt(e.variance)
+}
+
+// Interval returns the sampling interval
+func (e *EWMAEstimator) Interval() time.Duration {
+	return e.sampler.interval
+}
+
+// Ready reports whether at least minSamples returns have been seen
+func (e *EWMAEstimator) Ready() bool {
+	return e.samples >= e.minSamples
+}
+
+// Reset discards every sample
+func (e *EWMAEstimator) Reset() {
+	e.sampler.reset()
+	e.variance = 0
+	e.samples = 0
+	e.prevClose = 0
+}
@@ -0,0 +1,153 @@
+package volatility
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/ring"
+)
+
+var _ Estimator = (*Forecaster)(nil)
+
+// FitFunc fits a model to a window of returns
+type FitFunc func(returns []float64) (Model, error)
+
+// fitGARCH adapts FitGARCH to a FitFunc
+func fitGARCH(returns []float64) (Model, error) {
+	return FitGARCH(returns)
+}
+
+// fitEGARCH adapts FitEGARCH to a FitFunc
+func fitEGARCH(returns []float64) (Model, error) {
+	return FitEGARCH(returns)
+}
+
+// Forecaster forecasts volatility with a conditional variance model fitted
+// to a window of returns sampled at a fixed interval. The model is refitted
+// in the background every refitEvery returns, in between the conditional
+// variance is updated with the latest model on every return.
+// Forecaster implements Estimator, Volatility being the forecast.
+// Fitting needs a full window, which takes window·interval after startup, so
+// Reset keeps the returns and the fitted model rather than starting over.
+type Forecaster struct {
+	sampler    sampler
+	returns    *ring.Buffer[float64]
+	fit        FitFunc
+	refitEvery int // Returns between fits
+	horizon    int // Returns the forecast averages over
+
+	prevClose float64
+	model     Model
+	variance  float64 // Conditional variance of the next return
+
+	sinceFit int
+	fitting  bool
+	fitted   chan fitResult
+}
+
+type fitResult struct {
+	model Model
+	err   error
+}
+
+// NewForecaster creates a forecaster fitting the last window returns sampled
+// every interval, forecasting the mean volatility over horizon returns
+func NewForecaster(fit FitFunc, interval time.Duration, window, refitEvery, horizon int) *Forecaster {
+	return &Forecaster{
+		sampler:    sampler{interval: interval, maxGap: window},
+		returns:    ring.NewBuffer[float64](window),
+		fit:        fit,
+		refitEvery: refitEvery,
+		horizon:    horizon,
+		fitted:     make(chan fitResult, 1),
+	}
+}
+
+// Update adds the price observed at time t
+func (f *Forecaster) Update(t time.Time, price float64) {
+	f.install()
+	f.sampler.update(t, price, f.onBar)
+}
+
+func (f *Forecaster) onBar(bar Bar) {
+	if f.prevClose > 0 {
+		r := math.Log(bar.Close / f.prevClose)
+		f.returns.Push(r)
+		if f.model != nil {
+			f.variance = f.model.Next(f.variance, r)
+		}
+		f.sinceFit++
+		f.refit()
+	}
+	f.prevClose = bar.Close
+}
+
+// refit starts fitting a copy of the window in the background when due
+func (f *Forecaster) refit() {
+	if f.fitting || !f.returns.Full() || (f.model != nil && f.sinceFit < f.refitEvery) {
+		return
+	}
+	returns := make([]float64, 0, f.returns.Len())
+	f.returns.Do(func(r float64) { returns = append(returns, r) })
+
+	f.fitting = true
+	f.sinceFit = 0
+	go func() {
+		model, err := f.fit(returns)
+		f.fitted <- fitResult{model: model, err: err}
+	}()
+}
+
+// install switches to a model fitted in the background, if one is done
+func (f *Forecaster) install() {
+	select {
+	case result := <-f.fitted:
+		f.fitting = false
+		if result.err != nil {
+			log.Printf("Error fitting volatility model, keeping the previous one: %v", result.err)
+			return
+		}
+		f.model = result.model
+		returns := make([]float64, 0, f.returns.Len())
+		f.returns.Do(func(r float64) { returns = append(returns, r) })
+		f.variance = Filter(f.model, returns)
+	default:
+	}
+}
+
+// Model returns the latest fitted model, nil until the first fit completes
+func (f *Forecaster) Model() Model {
+	return f.model
+}
+
+// Forecast returns the mean volatility per interval over the next steps returns
+func (f *Forecaster) Forecast(steps int) float64 {
+	if f.model == nil {
+		return 0
+	}
+	return math.Sqrt(f.model.Forecast(f.variance, steps))
+}
+
+// Volatility returns the forecast volatility per interval over the horizon
+func (f *Forecaster) Volatility() float64 {
+	return f.Forecast(f.horizon)
+}
+
+// Interval returns the sampling interval
+func (f *Forecaster) Interval() time.Duration {
+	return f.sampler.interval
+}
+
+// Ready reports whether a model has been fitted
+func (f *Forecaster) Ready() bool {
+	return f.model != nil
+}
+
+// Reset discards the bar being sampled, so no return is measured across a
+// gap in the prices. The window of returns and the fitted model are kept, so
+// the forecast stays available when prices resume, e.g. after a reconnect.
+func (f *Forecaster) Reset() {
+	f.sampler.reset()
+	f.prevClose = 0
+}
@@ -0,0 +1,219 @@
+package volatility
+
+import (
+	"errors"
+	"math"
+)
+
+// minFitReturns is the minimum number of returns to fit a GARCH model to
+const minFitReturns = 100
+
+// Model is a fitted conditional variance model of returns
+type Model interface {
+	// Next returns the variance of the next return given the variance and
+	// value of the latest return
+	Next(variance, r float64) float64
+	// Forecast returns the mean variance per return over the next steps
+	// returns, given the variance of the next one
+	Forecast(next float64, steps int) float64
+}
+
+var _ Model = GARCHParams{}
+var _ Model = EGARCHParams{}
+
+// GARCHParams are the parameters of a GARCH(1,1) model
+//
+//	variance[t] = Omega + Alpha*r[t-1]^2 + Beta*variance[t-1]
+type GARCHParams struct {
+	Omega float64
+	Alpha float64
+	Beta  float64
+}
+
+// Next returns the variance of the next return
+func (p GARCHParams) Next(variance, r float64) float64 {
+	return p.Omega + p.Alpha*r*r + p.Beta*variance
+}
+
+// LongRunVariance returns the unconditional variance the model reverts to
+func (p GARCHParams) LongRunVariance() float64 {
+	return p.Omega / (1 - p.Alpha - p.Beta)
+}
+
+// Forecast returns the mean variance over the next steps returns. The
+// variance reverts to its long-run level at rate Alpha + Beta per step.
+func (p GARCHParams) Forecast(next float64, steps int) float64 {
+	if steps < 1 {
+		steps = 1
+	}
+	longRun := p.LongRunVariance()
+	persistence := p.Alpha + p.Beta
+
+	var sum float64
+	decay := 1.0
+	for k := 0; k < steps; k++ {
+		sum += longRun + decay*(next-longRun)
+		decay *= persistence
+	}
+	return sum / float64(steps)
+}
+
+// EGARCHParams are the parameters of an EGARCH(1,1) model, which captures
+// the asymmetric response of volatility to falling and rising prices
+//
+//	log variance[t] = Omega + Alpha*(|z[t-1]| - sqrt(2/pi)) + Gamma*z[t-1] + Beta*log variance[t-1]
+//
+// where z is the return divided by its conditional standard deviation
+type EGARCHParams struct {
+	Omega float64
+	Alpha float64
+	Gamma float64
+	Beta  float64
+}
+
+// Next returns the variance of the next return
+func (p EGARCHParams) Next(variance, r float64) float64 {
+	z := r / math.Sqrt(variance)
+	logVariance := p.Omega + p.Alpha*(math.Abs(z)-math.Sqrt(2/math.Pi)) + p.Gamma*z + p.Beta*math.Log(variance)
+	// Keep extreme returns from overflowing the recursion
+	return math.Exp(math.Max(math.Min(logVariance, 50), -50))
+}
+
+// Forecast returns the mean variance over the next steps returns. Future
+// shocks have zero expectation in log space, which ignores the convexity
+// correction and slightly understates longer horizons.
+func (p EGARCHParams) Forecast(next float64, steps int) float64 {
+	if steps < 1 {
+		steps = 1
+	}
+	var sum float64
+	logVariance := math.Log(next)
+	for k := 0; k < steps; k++ {
+		sum += math.Exp(logVariance)
+		logVariance = p.Omega + p.Beta*logVariance
+	}
+	return sum / float64(steps)
+}
+
+// FitGARCH fits a GARCH(1,1) model to zero mean returns by maximum
+// likelihood under normally distributed shocks
+func FitGARCH(returns []float64) (GARCHParams, error) {
+	scaled, scale, err := standardize(returns)
+	if err != nil {
+		return GARCHParams{}, err
+	}
+
+	// The parameters are mapped so every candidate is a stationary model:
+	// omega > 0, alpha, beta >= 0 and alpha + beta < 1
+	params := func(x []float64) GARCHParams {
+		persistence := maxPersistence * logistic(x[1])
+		share := logistic(x[2])
+		return GARCHParams{
+			Omega: math.Exp(x[0]),
+			Alpha: persistence * share,
+			Beta:  persistence * (1 - share),
+		}
+	}
+	start := []float64{math.Log(0.05), logit(0.95 / maxPersistence), logit(0.05 / 0.95)}
+	x, _ := minimize(func(x []float64) float64 {
+		return negativeLogLikelihood(params(x), scaled)
+	}, start, 0.5, 2000, 1e-10)
+
+	fitted := params(x)
+	// Undo the standardization, only omega carries the units of variance
+	fitted.Omega *= scale * scale
+	return fitted, nil
+}
+
+// FitEGARCH fits an EGARCH(1,1) model to zero mean returns by maximum
+// likelihood under normally distributed shocks
+func FitEGARCH(returns []float64) (EGARCHParams, error) {
+	scaled, scale, err := standardize(returns)
+	if err != nil {
+		return EGARCHParams{}, err
+	}
+
+	// Beta is mapped into (-1, 1) so the log variance is stationary
+	params := func(x []float64) EGARCHParams {
+		return EGARCHParams{
+			Omega: x[0],
+			Alpha: x[1],
+			Gamma: x[2],
+			Beta:  maxPersistence * math.Tanh(x[3]),
+		}
+	}
+	start := []float64{0, 0.1, 0, math.Atanh(0.95 / maxPersistence)}
+	x, _ := minimize(func(x []float64) float64 {
+		return negativeLogLikelihood(params(x), scaled)
+	}, start, 0.5, 2000, 1e-10)
+
+	fitted := params(x)
+	// Undo the standardization, which shifts the log variance by log(scale^2)
+	fitted.Omega += (1 - fitted.Beta) * math.Log(scale*scale)
+	return fitted, nil
+}
+
+// maxPersistence keeps fitted models strictly stationary
+const maxPersistence = 0.9999
+
+// Filter runs the model over the returns, starting from their sample
+// variance, and returns the variance of the return that follows them
+func Filter(m Model, returns []float64) float64 {
+	variance := sampleVariance(returns)
+	for _, r := range returns {
+		variance = m.Next(variance, r)
+	}
+	return variance
+}
+
+// negativeLogLikelihood returns the Gaussian negative log-likelihood of the
+// returns under the model, up to a constant
+func negativeLogLikelihood(m Model, returns []float64) float64 {
+	variance := sampleVariance(returns)
+	var nll float64
+	for _, r := range returns {
+		if variance <= 0 || math.IsNaN(variance) || math.IsInf(variance, 0) {
+			return math.Inf(1)
+		}
+		nll += 0.5 * (math.Log(variance) + r*r/variance)
+		variance = m.Next(variance, r)
+	}
+	return nll
+}
+
+// standardize divides the returns by their root mean square, which keeps
+// the optimizer's step sizes meaningful whatever the sampling interval
+func standardize(returns []float64) ([]float64, float64, error) {
+	if len(returns) < minFitReturns {
+		return nil, 0, errors.New("not enough returns to fit")
+	}
+	scale := math.Sqrt(sampleVariance(returns))
+	if scale == 0 {
+		return nil, 0, errors.New("returns have no variance")
+	}
+	scaled := make([]float64, len(returns))
+	for i, r := range returns {
+		scaled[i] = r / scale
+	}
+	return scaled, scale, nil
+}
+
+// sampleVariance returns the mean square of zero mean returns
+func sampleVariance(returns []float64) float64 {
+	if len(returns) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, r := range returns {
+		sum += r * r
+	}
+	return sum / float64(len(returns))
+}
+
+func logistic(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+func logit(p float64) float64 {
+	return math.Log(p / (1 - p))
+}
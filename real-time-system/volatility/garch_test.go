@@ -0,0 +1,156 @@
+package volatility
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// simulate draws returns from the model starting at its next variance
+func simulate(m Model, variance float64, n int, seed int64) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	returns := make([]float64, n)
+	for i := range returns {
+		returns[i] = math.Sqrt(variance) * rng.NormFloat64()
+		variance = m.Next(variance, returns[i])
+	}
+	return returns
+}
+
+func TestFitGARCHRecoversParameters(t *testing.T) {
+	truth := GARCHParams{Omega: 5e-8, Alpha: 0.1, Beta: 0.85}
+	returns := simulate(truth, truth.LongRunVariance(), 10000, 1)
+
+	fitted, err := FitGARCH(returns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(fitted.Alpha-truth.Alpha) > 0.03 || math.Abs(fitted.Beta-truth.Beta) > 0.05 {
+		t.Errorf("Expected parameters close to %+v, got %+v", truth, fitted)
+	}
+	if ratio := fitted.LongRunVariance() / truth.LongRunVariance(); ratio < 0.8 || ratio > 1.25 {
+		t.Errorf("Expected long-run variance close to %g, got %g", truth.LongRunVariance(), fitted.LongRunVariance())
+	}
+}
+
+func TestFitEGARCHRecoversAsymmetry(t *testing.T) {
+	// Falling prices raise volatility more than rising ones
+	truth := EGARCHParams{Omega: -0.7, Alpha: 0.15, Gamma: -0.08, Beta: 0.95}
+	returns := simulate(truth, math.Exp(truth.Omega/(1-truth.Beta)), 10000, 2)
+
+	fitted, err := FitEGARCH(returns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(fitted.Gamma-truth.Gamma) > 0.04 || math.Abs(fitted.Beta-truth.Beta) > 0.03 || math.Abs(fitted.Alpha-truth.Alpha) > 0.05 {
+		t.Errorf("Expected parameters close to %+v, got %+v", truth, fitted)
+	}
+}
+
+func TestFitRejectsDegenerateReturns(t *testing.T) {
+	if _, err := FitGARCH(make([]float64, 10)); err == nil {
+		t.Error("Expected an error for too few returns")
+	}
+	if _, err := FitEGARCH(make([]float64, minFitReturns)); err == nil {
+		t.Error("Expected an error for returns without variance")
+	}
+}
+
+func TestGARCHForecastRevertsToLongRun(t *testing.T) {
+	p := GARCHParams{Omega: 1e-7, Alpha: 0.1, Beta: 0.8}
+	longRun := p.LongRunVariance()
+	next := 4 * longRun
+
+	if got := p.Forecast(next, 1); got != next {
+		t.Errorf("Expected a one step forecast of %g, got %g", next, got)
+	}
+	// The excess decays by 0.9 per step, so the steps are 4 and 3.7 times the long-run variance
+	if got := p.Forecast(next, 2); math.Abs(got-3.85*longRun) > 1e-18 {
+		t.Errorf("Expected a two step forecast of %g, got %g", 3.85*longRun, got)
+	}
+	if got := p.Forecast(next, 100000); math.Abs(got/longRun-1) > 0.01 {
+		t.Errorf("Expected a long horizon forecast close to %g, got %g", longRun, got)
+	}
+}
+
+func TestForecasterRefitsInBackground(t *testing.T) {
+	truth := GARCHParams{Omega: 5e-8, Alpha: 0.1, Beta: 0.85}
+	returns := simulate(truth, truth.LongRunVariance(), 1000, 3)
+
+	fits := make(chan struct{}, 10)
+	fit := func(returns []float64) (Model, error) {
+		fits <- struct{}{}
+		return FitGARCH(returns)
+	}
+	f := NewForecaster(fit, time.Second, 500, 200, 10)
+
+	price := 30000.0
+	at := func(i int) time.Time { return start.Add(time.Duration(i) * time.Second) }
+	f.Update(at(0), price)
+	for i, r := range returns[:500] {
+		price *= math.Exp(r)
+		f.Update(at(i+1), price)
+	}
+	if f.Ready() {
+		t.Fatal("Expected no model before the window is full")
+	}
+
+	// The 500th return completes when the next bar opens and starts a fit
+	f.Update(at(501), price)
+	<-fits
+	deadline := time.Now().Add(5 * time.Second)
+	for !f.Ready() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the first fit")
+		}
+		time.Sleep(time.Millisecond)
+		f.Update(at(501), price)
+	}
+
+	model := f.Model().(GARCHParams)
+	if expected := math.Sqrt(model.Forecast(f.variance, 10)); f.Volatility() != expected {
+		t.Errorf("Expected volatility %g, got %g", expected, f.Volatility())
+	}
+	if f.Volatility() <= 0 || f.Forecast(1) <= 0 {
+		t.Error("Expected a positive forecast")
+	}
+
+	// A refit is only started after 200 more returns
+	for i, r := range returns[500:699] {
+		price *= math.Exp(r)
+		f.Update(at(i+502), price)
+	}
+	select {
+	case <-fits:
+		t.Fatal("Expected no refit before 200 returns")
+	default:
+	}
+	price *= math.Exp(returns[699])
+	f.Update(at(701), price)
+	f.Update(at(702), price)
+	select {
+	case <-fits:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the refit")
+	}
+
+	for f.fitting {
+		time.Sleep(time.Millisecond)
+		f.Update(at(702), price)
+	}
+
+	// A reset after a gap keeps the model and the window of returns
+	volatility := f.Volatility()
+	f.Reset()
+	if !f.Ready() || f.Volatility() != volatility || f.returns.Len() != 500 {
+		t.Error("Expected the forecast to survive a reset")
+	}
+
+	// No return is measured across the gap
+	f.Update(at(800), price*2)
+	f.Update(at(801), price*2)
+	if f.returns.Len() != 500 || f.Volatility() != volatility {
+		t.Error("Expected no return across the gap")
+	}
+}
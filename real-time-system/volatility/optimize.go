@@ -0,0 +1,80 @@
+package volatility
+
+import (
+	"math"
+	"sort"
+)
+
+// minimize finds a local minimum of f with the Nelder-Mead simplex method,
+// starting from x0 with initial steps of size step. It needs no gradients,
+// which suits likelihoods of recursive models like GARCH.
+func minimize(f func([]float64) float64, x0 []float64, step float64, maxIter int, tolerance float64) ([]float64, float64) {
+	n := len(x0)
+	type vertex struct {
+		x []float64
+		v float64
+	}
+	eval := func(x []float64) vertex { return vertex{x, f(x)} }
+
+	simplex := make([]vertex, n+1)
+	simplex[0] = eval(append([]float64(nil), x0...))
+	for i := 0; i < n; i++ {
+		x := append([]float64(nil), x0...)
+		x[i] += step
+		simplex[i+1] = eval(x)
+	}
+
+	// point returns centroid + coef * (centroid - worst)
+	point := func(centroid, worst []float64, coef float64) []float64 {
+		x := make([]float64, n)
+		for i := range x {
+			x[i] = centroid[i] + coef*(centroid[i]-worst[i])
+		}
+		return x
+	}
+
+	for iter := 0; iter < maxIter; iter++ {
+		sort.Slice(simplex, func(i, j int) bool { return simplex[i].v < simplex[j].v })
+		best, worst := simplex[0], simplex[n]
+		if worst.v-best.v <= tolerance*(1+math.Abs(best.v)) {
+			break
+		}
+
+		centroid := make([]float64, n)
+		for _, s := range simplex[:n] {
+			for i := range centroid {
+				centroid[i] += s.x[i] / float64(n)
+			}
+		}
+
+		reflected := eval(point(centroid, worst.x, 1))
+		switch {
+		case reflected.v < best.v:
+			expanded := eval(point(centroid, worst.x, 2))
+			if expanded.v < reflected.v {
+				simplex[n] = expanded
+			} else {
+				simplex[n] = reflected
+			}
+		case reflected.v < simplex[n-1].v:
+			simplex[n] = reflected
+		default:
+			contracted := eval(point(centroid, worst.x, -0.5))
+			if contracted.v < worst.v {
+				simplex[n] = contracted
+				continue
+			}
+			// Shrink towards the best vertex
+			for i := 1; i <= n; i++ {
+				x := make([]float64, n)
+				for j := range x {
+					x[j] = best.x[j] + 0.5*(simplex[i].x[j]-best.x[j])
+				}
+				simplex[i] = eval(x)
+			}
+		}
+	}
+
+	sort.Slice(simplex, func(i, j int) bool { return simplex[i].v < simplex[j].v })
+	return simplex[0].x, simplex[0].v
+}
@@ -0,0 +1,65 @@
+package volatility
+
+import (
+	"math"
+	"time"
+)
+
+var _ Estimator = (*RangeEstimator)(nil)
+
+// RangeEstimator estimates volatility from the OHLC bars of each interval
+// with the Parkinson or Garman-Klass estimator. Both use the intra-bar range
+// and so are several times more efficient than close-to-close returns, but
+// are biased low when prices are sampled sparsely within a bar.
+type RangeEstimator struct {
+	method   Method
+	sampler  sampler
+	variance *rollingMean // Per-bar variance estimates
+}
+
+// NewRange creates a Parkinson or Garman-Klass estimator over the last window bars
+func NewRange(method Method, interval time.Duration, window int) *RangeEstimator {
+	return &RangeEstimator{
+		method:   method,
+		sampler:  sampler{interval: interval, maxGap: window},
+		variance: newRollingMean(window),
+	}
+}
+
+// Update adds the price observed at time t
+func (e *RangeEstimator) Update(t time.Time, price float64) {
+	e.sampler.update(t, price, e.onBar)
+}
+
+func (e *RangeEstimator) onBar(bar Bar) {
+	hl := math.Log(bar.High / bar.Low)
+	switch e.method {
+	case GarmanKlass:
+		co := math.Log(bar.Close / bar.Open)
+		e.variance.add(0.5*hl*hl - (2*math.Ln2-1)*co*co)
+	default:
+		e.variance.add(hl * hl / (4 * math.Ln2))
+	}
+}
+
+// Volatility returns the volatility per bar interval
+func (e *RangeEstimator) Volatility() float64 {
+	// Garman-Klass terms can be negative for individual bars
+	return math.Sqrt(math.Max(e.variance.mean(), 0))
+}
+
+// Interval returns the bar length
+func (e *RangeEstimator) Interval() time.Duration {
+	return e.sampler.interval
+}
+
+// Ready reports whether the window of bars is full
+func (e *RangeEstimator) Ready() bool {
+	return e.variance.full()
+}
+
+// Reset discards every bar
+func (e *RangeEstimator) Reset() {
+	e.sampler.reset()
+	e.variance.reset()
+}
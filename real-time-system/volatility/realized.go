@@ -0,0 +1,60 @@
+package volatility
+
+import (
+	"math"
+	"time"
+)
+
+var _ Estimator = (*RealizedEstimator)(nil)
+
+// RealizedEstimator is the realized volatility of close-to-close log returns
+// of prices sampled at a fixed interval. Returns are assumed to have zero
+// mean, which is standard at high frequency where the mean is mostly noise.
+type RealizedEstimator struct {
+	sampler   sampler
+	squares   *rollingMean // Squared log returns
+	prevClose float64
+}
+
+// NewRealized creates an estimator over the last window returns sampled every interval
+func NewRealized(interval time.Duration, window int) *RealizedEstimator {
+	return &RealizedEstimator{
+		sampler: sampler{interval: interval, maxGap: window},
+		squares: newRollingMean(window),
+	}
+}
+
+// Update adds the price observed at time t
+func (e *RealizedEstimator) Update(t time.Time, price float64) {
+	e.sampler.update(t, price, e.onBar)
+}
+
+func (e *RealizedEstimator) onBar(bar Bar) {
+	if e.prevClose > 0 {
+		r := math.Log(bar.Close / e.prevClose)
+		e.squares.add(r * r)
+	}
+	e.prevClose = bar.Close
+}
+
+// Volatility returns the root mean square log return per interval
+func (e *RealizedEstimator) Volatility() float64 {
+	return math.Sqrt(e.squares.mean())
+}
+
+// Interval returns the sampling interval
+func (e *RealizedEstimator) Interval() time.Duration {
+	return e.sampler.interval
+}
+
+// Ready reports whether the window of returns is full
+func (e *RealizedEstimator) Ready() bool {
+	return e.squares.full()
+}
+
+// Reset discards every sample
+func (e *RealizedEstimator) Reset() {
+	e.sampler.reset()
+	e.squares.reset()
+	e.prevClose = 0
+}
@@ -0,0 +1,245 @@
+package volatility
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/369geofreeman/inventory-control/real-time-system/ring"
+)
+
+// Year is the period volatility is annualized over. Crypto trades around
+// the clock, so a year is 365 days rather than 252 trading days.
+const Year = 365 * 24 * time.Hour
+
+// Estimator estimates volatility from a stream of prices, e.g. mid-prices.
+// Implementations are not safe for concurrent use.
+type Estimator interface {
+	// Update adds the price observed at time t. Times must not go backwards.
+	Update(t time.Time, price float64)
+	// Volatility returns the standard deviation of log returns per Interval
+	Volatility() float64
+	// Interval returns the sampling interval the volatility is measured over
+	Interval() time.Duration
+	// Ready reports whether enough samples have been seen for an estimate
+	Ready() bool
+	// Reset discards every sample
+	Reset()
+}
+
+// Annualized returns the estimator's volatility scaled to a year
+func Annualized(e Estimator) float64 {
+	return Scale(e.Volatility(), e.Interval(), Year)
+}
+
+// Scale converts a volatility measured over one period to another, assuming
+// independent returns so variance grows linearly with time
+func Scale(volatility float64, from, to time.Duration) float64 {
+	if from <= 0 {
+		return 0
+	}
+	return volatility * math.Sqrt(float64(to)/float64(from))
+}
+
+// Method selects an estimator
+type Method string
+
+const (
+	Realized    Method = "realized"     // Close-to-close log returns
+	Parkinson   Method = "parkinson"    // High-low range of OHLC bars
+	GarmanKlass Method = "garman-klass" // Open-high-low-close of OHLC bars
+	EWMA        Method = "ewma"         // Exponentially weighted close-to-close log returns
+	GARCH       Method = "garch"        // GARCH(1,1) forecast of close-to-close log returns
+	EGARCH      Method = "egarch"       // EGARCH(1,1) forecast of close-to-close log returns
+)
+
+// Config configures an estimator, typically per symbol
+type Config struct {
+	Method   Method        // Realized by default
+	Interval time.Duration // Sampling interval or bar length, 1s by default
+	Window   int           // Number of returns or bars in the window, 60 by default and 500 for GARCH
+	Lambda   float64       // Decay factor for EWMA, 0.94 by default
+
+	// Minimum number of returns before EWMA is ready, Window by default
+	MinSamples int
+
+	// GARCH and EGARCH only
+	Horizon    time.Duration // Horizon the forecast volatility is averaged over, Interval by default
+	RefitEvery int           // Returns between refits of the model, 60 by default
+}
+
+// withDefaults returns the config with zero values replaced by defaults
+func (c Config) withDefaults() Config {
+	if c.Method == "" {
+		c.Method = Realized
+	}
+	if c.Interval == 0 {
+		c.Interval = time.Second
+	}
+	if c.Window == 0 {
+		c.Window = 60
+		if c.Method == GARCH || c.Method == EGARCH {
+			c.Window = 500
+		}
+	}
+	if c.Lambda == 0 {
+		c.Lambda = 0.94
+	}
+	if c.MinSamples == 0 {
+		c.MinSamples = c.Window
+	}
+	if c.Horizon == 0 {
+		c.Horizon = c.Interval
+	}
+	if c.RefitEvery == 0 {
+		c.RefitEvery = 60
+	}
+	return c
+}
+
+// New creates the estimator selected by the config
+func New(config Config) (Estimator, error) {
+	config = config.withDefaults()
+	if config.Interval <= 0 {
+		return nil, errors.New("interval should be greater than 0")
+	}
+	if config.Window < 2 {
+		return nil, errors.New("window should be at least 2")
+	}
+	if config.MinSamples < 1 {
+		return nil, errors.New("min samples should be at least 1")
+	}
+
+	switch config.Method {
+	case Realized:
+		return NewRealized(config.Interval, config.Window), nil
+	case Parkinson, GarmanKlass:
+		return NewRange(config.Method, config.Interval, config.Window), nil
+	case EWMA:
+		if config.Lambda <= 0 || config.Lambda >= 1 {
+			return nil, errors.New("lambda should be between 0 and 1")
+		}
+		return NewEWMA(config.Interval, config.Lambda, config.MinSamples), nil
+	case GARCH, EGARCH:
+		if config.Window < minFitReturns {
+			return nil, fmt.Errorf("window should be at least %d to fit %s", minFitReturns, config.Method)
+		}
+		if config.Horizon < config.Interval || config.RefitEvery < 1 {
+			return nil, errors.New("horizon should be at least one interval and refits at least every return")
+		}
+		fit := fitGARCH
+		if config.Method == EGARCH {
+			fit = fitEGARCH
+		}
+		horizon := int(config.Horizon / config.Interval)
+		return NewForecaster(fit, config.Interval, config.Window, config.RefitEvery, horizon), nil
+	}
+	return nil, fmt.Errorf("unknown volatility method %q", config.Method)
+}
+
+// Bar is the open, high, low and close price over one interval
+type Bar struct {
+	Start                  time.Time
+	Open, High, Low, Close float64
+}
+
+// sampler aggregates prices into bars of a fixed interval aligned to the
+// clock. Intervals without prices produce flat bars at the previous close.
+type sampler struct {
+	interval time.Duration
+	maxGap   int // Maximum number of flat bars emitted for a gap
+	bar      Bar
+	started  bool
+}
+
+// update adds a price and calls onBar for every bar completed before t
+func (s *sampler) update(t time.Time, price float64, onBar func(Bar)) {
+	if price <= 0 {
+		return
+	}
+	start := t.Truncate(s.interval)
+	if !s.started {
+		s.bar = Bar{Start: start, Open: price, High: price, Low: price, Close: price}
+		s.started = true
+		return
+	}
+	if start.Before(s.bar.Start) {
+		// Out of order price, already accounted for
+		return
+	}
+
+	if start.After(s.bar.Start) {
+		onBar(s.bar)
+
+		// Flat bars for intervals without prices, longer gaps only need
+		// enough to fill the window
+		gaps := int(start.Sub(s.bar.Start)/s.interval) - 1
+		if gaps > s.maxGap {
+			gaps = s.maxGap
+		}
+		last := s.bar.Close
+		for i := 0; i < gaps; i++ {
+			onBar(Bar{Start: s.bar.Start.Add(time.Duration(i+1) * s.interval), Open: last, High: last, Low: last, Close: last})
+		}
+
+		s.bar = Bar{Start: start, Open: price, High: price, Low: price, Close: price}
+		return
+	}
+
+	s.bar.High = math.Max(s.bar.High, price)
+	s.bar.Low = math.Min(s.bar.Low, price)
+	s.bar.Close = price
+}
+
+// reset discards the bar in progress
+func (s *sampler) reset() {
+	s.bar = Bar{}
+	s.started = false
+}
+
+// rollingMean is the mean of the last n values with O(1) updates. The sum is
+// rebuilt from the buffer after every n evictions to bound rounding error.
+type rollingMean struct {
+	values    *ring.Buffer[float64]
+	sum       float64
+	evictions int
+}
+
+func newRollingMean(n int) *rollingMean {
+	return &rollingMean{values: ring.NewBuffer[float64](n)}
+}
+
+// add adds a value, evicting the oldest once the window is full
+func (m *rollingMean) add(x float64) {
+	if evicted, ok := m.values.Push(x); ok {
+		m.sum -= evicted
+		m.evictions++
+	}
+	m.sum += x
+
+	if m.evictions >= m.values.Cap() {
+		m.evictions = 0
+		m.sum = 0
+		m.values.Do(func(v float64) { m.sum += v })
+	}
+}
+
+// mean returns the mean of the window, 0 if it is empty
+func (m *rollingMean) mean() float64 {
+	if m.values.Len() == 0 {
+		return 0
+	}
+	return m.sum / float64(m.values.Len())
+}
+
+// full reports whether the window holds n values
+func (m *rollingMean) full() bool {
+	return m.values.Full()
+}
+
+func (m *rollingMean) reset() {
+	m.values.Reset()
+	m.sum = 0
+	m.evictions = 0
+}
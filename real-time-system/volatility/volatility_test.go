@@ -0,0 +1,163 @@
+package volatility
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestNewValidatesConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		valid  bool
+	}{
+		{"defaults", Config{}, true},
+		{"parkinson", Config{Method: Parkinson}, true},
+		{"garman-klass", Config{Method: GarmanKlass, Interval: time.Minute, Window: 30}, true},
+		{"ewma", Config{Method: EWMA, Lambda: 0.97}, true},
+		{"unknown method", Config{Method: "yang-zhang"}, false},
+		{"negative interval", Config{Interval: -time.Second}, false},
+		{"window too small", Config{Window: 1}, false},
+		{"lambda out of range", Config{Method: EWMA, Lambda: 1}, false},
+		{"garch", Config{Method: GARCH, Horizon: time.Minute}, true},
+		{"egarch", Config{Method: EGARCH, Window: 200, RefitEvery: 10}, true},
+		{"garch window too small", Config{Method: GARCH, Window: 60}, false},
+		{"garch horizon too short", Config{Method: GARCH, Horizon: time.Millisecond}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := New(tt.config)
+			if tt.valid && (err != nil || e == nil) {
+				t.Errorf("Expected a valid config, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestScale(t *testing.T) {
+	if got := Scale(0.001, time.Second, time.Minute); math.Abs(got-0.001*math.Sqrt(60)) > 1e-12 {
+		t.Errorf("Expected 1s volatility to scale by sqrt(60) to a minute, got %f", got)
+	}
+	e := NewRealized(time.Second, 2)
+	for i, price := range []float64{100, 101, 100, 101} {
+		e.Update(start.Add(time.Duration(i)*time.Second), price)
+	}
+	expected := math.Log(1.01) * math.Sqrt(Year.Seconds())
+	if got := Annualized(e); math.Abs(got-expected) > 1e-9 {
+		t.Errorf("Expected annualized volatility %f, got %f", expected, got)
+	}
+}
+
+func TestRealizedSamplesAtInterval(t *testing.T) {
+	e := NewRealized(time.Second, 3)
+
+	// Only the last price of each second counts, the 200 within the first
+	// second does not produce a return
+	e.Update(start, 100)
+	e.Update(start.Add(100*time.Millisecond), 200)
+	e.Update(start.Add(900*time.Millisecond), 100)
+	e.Update(start.Add(1500*time.Millisecond), 101)
+	if e.Ready() {
+		t.Fatal("Expected the estimator not to be ready after one bar")
+	}
+
+	// Two seconds without prices are flat returns
+	e.Update(start.Add(4*time.Second), 101)
+	if !e.Ready() {
+		t.Fatal("Expected three returns after the gap")
+	}
+	// Returns are ln(1.01), 0 and 0
+	expected := math.Log(1.01) / math.Sqrt(3)
+	if math.Abs(e.Volatility()-expected) > 1e-12 {
+		t.Errorf("Expected volatility %f, got %f", expected, e.Volatility())
+	}
+
+	e.Reset()
+	if e.Ready() || e.Volatility() != 0 {
+		t.Error("Expected no estimate after reset")
+	}
+}
+
+func TestRangeEstimators(t *testing.T) {
+	// Every one second bar opens at 100, trades up to 101 and closes at 100.5
+	bar := []float64{100, 101, 100.5}
+	feed := func(e Estimator) {
+		for i := 0; i < 4; i++ {
+			for j, price := range bar {
+				e.Update(start.Add(time.Duration(i)*time.Second+time.Duration(j)*100*time.Millisecond), price)
+			}
+		}
+	}
+	hl := math.Log(101.0 / 100)
+	co := math.Log(100.5 / 100)
+
+	parkinson := NewRange(Parkinson, time.Second, 3)
+	feed(parkinson)
+	if expected := math.Sqrt(hl * hl / (4 * math.Ln2)); !parkinson.Ready() || math.Abs(parkinson.Volatility()-expected) > 1e-12 {
+		t.Errorf("Expected Parkinson volatility %f, got %f (ready %v)", expected, parkinson.Volatility(), parkinson.Ready())
+	}
+
+	garmanKlass := NewRange(GarmanKlass, time.Second, 3)
+	feed(garmanKlass)
+	if expected := math.Sqrt(0.5*hl*hl - (2*math.Ln2-1)*co*co); math.Abs(garmanKlass.Volatility()-expected) > 1e-12 {
+		t.Errorf("Expected Garman-Klass volatility %f, got %f", expected, garmanKlass.Volatility())
+	}
+}
+
+func TestEWMAWeightsRecentReturns(t *testing.T) {
+	e := NewEWMA(time.Second, 0.5, 2)
+	prices := []float64{100, 101, 101, 101}
+	for i, price := range prices {
+		e.Update(start.Add(time.Duration(i)*time.Second), price)
+	}
+	// Returns ln(1.01) and 0 have completed, the last bar is still open
+	r := math.Log(1.01)
+	if expected := math.Sqrt(0.5 * r * r); !e.Ready() || math.Abs(e.Volatility()-expected) > 1e-12 {
+		t.Errorf("Expected EWMA volatility %f, got %f (ready %v)", expected, e.Volatility(), e.Ready())
+	}
+}
+
+func TestEstimatorsRecoverKnownVolatility(t *testing.T) {
+	// Geometric Brownian motion sampled 100 times per second with 1s
+	// volatility sigma
+	const sigma = 0.001
+	const steps = 100
+	rng := rand.New(rand.NewSource(1))
+
+	estimators := map[string]struct {
+		estimator Estimator
+		tolerance float64
+	}{
+		"realized":     {NewRealized(time.Second, 2000), 0.05},
+		"parkinson":    {NewRange(Parkinson, time.Second, 2000), 0.1},
+		"garman-klass": {NewRange(GarmanKlass, time.Second, 2000), 0.1},
+		"ewma":         {NewEWMA(time.Second, 0.999, 2000), 0.1},
+	}
+
+	price := 30000.0
+	for i := 0; i < 2002*steps; i++ {
+		at := start.Add(time.Duration(i) * time.Second / steps)
+		for _, e := range estimators {
+			e.estimator.Update(at, price)
+		}
+		price *= math.Exp(sigma / math.Sqrt(steps) * rng.NormFloat64())
+	}
+
+	for name, e := range estimators {
+		if !e.estimator.Ready() {
+			t.Errorf("%s: expected to be ready", name)
+			continue
+		}
+		// Discrete sampling within a bar biases range estimators low
+		if got := e.estimator.Volatility(); math.Abs(got-sigma)/sigma > e.tolerance {
+			t.Errorf("%s: expected volatility within %.0f%% of %f, got %f", name, e.tolerance*100, sigma, got)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package wsconn
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const writeTimeout = 10 * time.Second
+
+// ErrMissedPong is returned by Heartbeat when a ping was not answered in time
+var ErrMissedPong = errors.New("no pong received")
+
+// Conn wraps a WebSocket connection shared by a read loop and a heartbeat.
+// gorilla/websocket supports only one concurrent writer, so writes are
+// serialized. Venues reply to application level pings in their own format,
+// so the read loop reports them with Pong.
+type Conn struct {
+	*websocket.Conn
+	writeMu sync.Mutex
+
+	pongMu   sync.Mutex
+	lastPong time.Time
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Dial opens a WebSocket connection to url, which is closed once ctx is
+// cancelled so the read loop stops
+func Dial(ctx context.Context, url string) (*Conn, error) {
+	ws, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{Conn: ws, closed: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-c.closed:
+		}
+	}()
+	return c, nil
+}
+
+// Close closes the connection, closing it again does nothing
+func (c *Conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		if c.closed != nil {
+			close(c.closed)
+		}
+		err = c.Conn.Close()
+	})
+	return err
+}
+
+// WriteJSON writes a JSON message with a write deadline
+func (c *Conn) WriteJSON(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return c.Conn.WriteJSON(v)
+}
+
+// WriteText writes a plain text message with a write deadline
+func (c *Conn) WriteText(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return c.WriteMessage(websocket.TextMessage, data)
+}
+
+// Read reads the next message, failing if none arrives within timeout. The
+// read deadline acts as a watchdog for half-open connections.
+func (c *Conn) Read(timeout time.Duration) ([]byte, error) {
+	c.SetReadDeadline(time.Now().Add(timeout))
+	_, message, err := c.ReadMessage()
+	return message, err
+}
+
+// IsTimeout reports whether a Read failed because no message arrived in time
+func IsTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Pong records that the server answered a ping
+func (c *Conn) Pong() {
+	c.pongMu.Lock()
+	defer c.pongMu.Unlock()
+	c.lastPong = time.Now()
+}
+
+// ponged reports whether a pong arrived since the given time
+func (c *Conn) ponged(since time.Time) bool {
+	c.pongMu.Lock()
+	defer c.pongMu.Unlock()
+	return !c.lastPong.Before(since)
+}
+
+// Heartbeat calls ping every interval until done is closed, which returns
+// nil. The connection is closed when the previous ping was not answered
+// within the interval or a ping fails, which unblocks the read loop so it
+// can reconnect, and ErrMissedPong or the ping's error is returned.
+func (c *Conn) Heartbeat(interval time.Duration, ping func() error, done <-chan struct{}) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastPing := time.Time{}
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-ticker.C:
+		}
+
+		if !lastPing.IsZero() && !c.ponged(lastPing) {
+			log.Printf("No pong received within %s, reconnecting", interval)
+			c.Close()
+			return ErrMissedPong
+		}
+
+		if err := ping(); err != nil {
+			log.Println("Error sending ping:", err)
+			c.Close()
+			return err
+		}
+		lastPing = time.Now()
+	}
+}
@@ -0,0 +1,127 @@
+package wsconn
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// echoServer answers "ping" with "pong" unless silent
+func echoServer(t *testing.T, silent bool) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer ws.Close()
+		for {
+			_, message, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			if string(message) == "ping" && !silent {
+				ws.WriteMessage(websocket.TextMessage, []byte("pong"))
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// heartbeat runs a heartbeat and a read loop reporting pongs until the
+// connection fails or done is closed
+func heartbeat(t *testing.T, conn *Conn, done chan struct{}) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- conn.Heartbeat(50*time.Millisecond, func() error {
+			return conn.WriteText([]byte("ping"))
+		}, done)
+	}()
+	go func() {
+		for {
+			message, err := conn.Read(time.Second)
+			if err != nil {
+				return
+			}
+			if string(message) == "pong" {
+				conn.Pong()
+			}
+		}
+	}()
+	return result
+}
+
+func TestHeartbeat(t *testing.T) {
+	conn, err := Dial(context.Background(), echoServer(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	result := heartbeat(t, conn, done)
+	time.Sleep(300 * time.Millisecond)
+	close(done)
+	if err := <-result; err != nil {
+		t.Errorf("Expected answered pings to keep the connection open, got %v", err)
+	}
+}
+
+func TestHeartbeatMissedPong(t *testing.T) {
+	conn, err := Dial(context.Background(), echoServer(t, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	select {
+	case err := <-heartbeat(t, conn, done):
+		if !errors.Is(err, ErrMissedPong) {
+			t.Errorf("Expected ErrMissedPong, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the missed pong")
+	}
+	if err := conn.WriteText([]byte("ping")); err == nil {
+		t.Error("Expected the connection to be closed")
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	conn, err := Dial(context.Background(), echoServer(t, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Read(10 * time.Millisecond); !IsTimeout(err) {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+	if IsTimeout(errors.New("closed")) {
+		t.Error("Expected other errors not to be timeouts")
+	}
+}
+
+func TestCloseOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := Dial(ctx, echoServer(t, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	cancel()
+	if _, err := conn.Read(5 * time.Second); err == nil || IsTimeout(err) {
+		t.Errorf("Expected cancelling to close the connection, got %v", err)
+	}
+}